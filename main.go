@@ -1,155 +1,96 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"os"
 	"path/filepath"
+	"time"
 
-	"github.com/Viking2012/goraynor/src/countr"
+	"github.com/Viking2012/goraynor/data/basis"
+	"github.com/Viking2012/goraynor/src/classifr"
 	"github.com/Viking2012/goraynor/src/getr"
-	"github.com/Viking2012/goraynor/src/quantilr"
+	"github.com/Viking2012/goraynor/src/structs"
+	"github.com/Viking2012/goraynor/src/transitions"
 )
 
-// const randSeed uint64 = 123456
-
-// func decileToIndex(d int) int {
-// 	return d - 1
-// }
-
-// func indexToDecile(i int) int {
-// 	return i + 1
-// }
-
-// type sparseArray map[int]float64
-
-// func newSparseArray() sparseArray {
-// 	sp := sparseArray{}
-// 	for i := 1; i <= 10; i++ {
-// 		sp[i] = 0
-// 	}
-// 	return sp
-// }
-
-// type transitionMatrix map[int]sparseArray
-
-// func newTransitionMatrix() transitionMatrix {
-// 	tm := transitionMatrix{}
-// 	for i := 1; i <= 10; i++ {
-// 		tm[i] = newSparseArray()
-// 	}
-
-// 	return tm
-// }
-
-// func (tm transitionMatrix) Add(i, j int) {
-// 	currentCount := tm[i][j]
-// 	currentCount += 1
-// 	tm[i][j] = currentCount
-// }
-
-// func (tm transitionMatrix) Load(transitions []decileTransition) {
-// 	for i := 0; i < len(transitions); i++ {
-// 		t := transitions[i]
-// 		tm.Add(t.this, t.next)
-// 	}
-// }
-
-// type transitionModel map[int]distuv.Categorical
-
-// func newTransitionModel(tm transitionMatrix) transitionModel {
-// 	newModel := transitionModel{}
-// 	src := rand.NewSource(randSeed)
-
-// 	for thisDecile := 1; thisDecile <= 10; thisDecile++ {
-// 		// collect the weights from this row of the transitionMatrix
-// 		weights := make([]float64, 10)
-// 		for thisWeight := 0; thisWeight < 10; thisWeight++ {
-// 			weights[thisWeight] = tm[thisDecile][indexToDecile(thisWeight)]
-// 		}
-
-// 		newModel[decileToIndex(thisDecile)] = distuv.NewCategorical(weights, src)
-// 	}
-
-// 	return newModel
-// }
-
-// func (tm transitionModel) Simulate(start int8, lifespan int16) countr.Counter {
-// 	var simulationResults []float64 = make([]float64, lifespan)
-// 	var thisPeriod int16
-// 	var thisStep int8 = start
-// 	// var randSource := rand.New
-// 	for thisPeriod = 0; thisPeriod < lifespan; thisPeriod++ {
-// 		fmt.Printf("For period %d, beginning simulation with value %d", thisPeriod, thisStep)
-// 		simulationResults[thisPeriod] = float64(thisStep + 1)
-// 		modelToBeUsed := tm[int(thisStep)-1]
-// 		thisStep = int8(modelToBeUsed.Rand())
-// 		fmt.Printf("For period %d, after simulation got value %d", thisPeriod, thisStep)
-// 	}
-// 	return countr.Count(simulationResults)
-// }
-
-// type decileTransition struct {
-// 	this int
-// 	next int
-// }
-
-// var transitions []decileTransition = []decileTransition{
-// 	{this: 1, next: 2},
-// 	{this: 2, next: 3},
-// 	{this: 3, next: 4},
-// 	{this: 4, next: 5},
-// 	{this: 5, next: 1},
-// 	{this: 1, next: 2},
-// 	{this: 2, next: 3},
-// 	{this: 3, next: 4},
-// 	{this: 4, next: 2},
-// 	{this: 2, next: 1},
-// }
+const randSeed uint64 = 123456
+
+var (
+	cacheDir = flag.String("cache", filepath.Join(".", "data", "cache"), "directory holding the cached price history of every ticker")
+	asOf     = flag.String("asof", "", "only use bars dated on or before this day (YYYY-MM-DD), to repeat a historical run")
+	update   = flag.Bool("update", false, "fetch any new bars for every ticker before running (tickers missing from the cache are always fetched)")
+)
+
+// cached reports whether the cache holds every ticker, so that a first run fetches them
+func cached(cache *getr.Cache, tickers []string) bool {
+	manifest := cache.Manifest()
+	for _, ticker := range tickers {
+		if _, ok := manifest[ticker]; !ok {
+			return false
+		}
+	}
+	return true
+}
 
 func main() {
-	today := "20210819" // otherwise, time.Now().Format("20060102")
-	saveDir := filepath.Join(".", "data", today)
-	_ = os.Mkdir(saveDir, os.ModeDir) // TODO(ajo): lazy ignoring of errors. Fix This!
+	flag.Parse()
 
-	// err := getr.DownloadTickers(saveDir)
-	// if err != nil {
-	// 	panic(err)
-	// }
+	var asOfDate time.Time
+	if *asOf != "" {
+		var err error
+		if asOfDate, err = time.Parse("2006-01-02", *asOf); err != nil {
+			panic(err)
+		}
+	}
 
-	pRecords, err := getr.GetTickers(saveDir)
+	cache, err := getr.OpenCache(*cacheDir)
 	if err != nil {
 		panic(err)
 	}
-
-	var allPrices []float64
-	for _, data := range *pRecords {
-		records := *data
-		// lastRecord := records[len(records)-1]
-		for i := 0; i < len(records); i++ {
-			allPrices = append(allPrices, records[i].PriceReturn)
+	cache.Period = structs.ByMonth // the provider resamples to monthly bars
+
+	if *update || !cached(cache, basis.TICKERS) {
+		provider := getr.NewTiingo(basis.ApiToken)
+		provider.ResampleFreq = basis.ResponseFrequencyMonthly
+		provider.Columns = basis.ResponseColumns
+		_, err := cache.Update(context.Background(), getr.NewManager(provider), basis.TICKERS, time.Now())
+		if err != nil {
+			fmt.Println(err) // the cache holds every ticker that did download
 		}
-		// fmt.Printf("\tfor ticker: %s, got %5d monthly records (%v)\n", ticker, len(*data), lastRecord)
 	}
 
-	c := countr.Count(allPrices)
-	d, err := quantilr.NewDeciles(c, true)
+	pRecords, err := cache.GetTickers(basis.TICKERS, asOfDate)
 	if err != nil {
 		panic(err)
 	}
-	fmt.Printf("All Prices\n%v\n", d.Pairs)
-	pRecords.SetDeciles(&d)
+
+	// rank each ticker against its peers month by month, rather than against pooled deciles
+	if _, err := pRecords.SetDecilesByPeriod(structs.ByMonth, structs.Unweighted); err != nil {
+		panic(err)
+	}
 
 	for ticker, data := range *pRecords {
 		fmt.Printf("\tfor ticker: %s, got %5d monthly records and returns:\n", ticker, len(*data))
 		records := *data
 		for i := 0; i < len(records); i++ {
 			thisRecord := records[i]
-			if thisRecord.DecileOfPrice != 0 {
+			if thisRecord.Bucket != 0 {
 				fmt.Printf("\t\t(%v)\n", thisRecord)
 			}
 		}
 	}
 
+	counts, err := transitions.Pooled(pRecords, 10)
+	if err != nil {
+		panic(err)
+	}
+	model, err := counts.Normalize(transitions.UniformRow)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Pooled transition probabilities\n%v\n", model)
+
 	// raw, err := readr.ParseCSV("./test/test_data.csv", 1, &readr.DefaultFieldMap)
 	// if err != nil {
 	// 	panic(err)
@@ -157,34 +98,13 @@ func main() {
 
 	// organizr.OrderedBy(organizr.ByProduct, organizr.ByCustomer, organizr.ByDate, organizr.ByDocumentNumber, organizr.ByDocumentLineNumber).Sort(raw)
 
-	// newMatr := newTransitionMatrix()
-	// newMatr.Load(transitions)
-
-	// fmt.Println(newMatr)
-
-	// _ = newTransitionModel(newMatr)
-
-	// ALL PREVIOUSLY COMMENTED
-	// var numSimulations int = 1
-	// var cp countr.Counter
-	// for i := 0; i < numSimulations; i++ {
-	// 	cp = models.Simulate(1, 100)
-	// }
-	// fmt.Println(cp)
-
-	// toCounter := make([]float64, numSimulations)
-
-	// weights := []float64{1, 10, 89}
-
-	// source := rand.NewSource(randSeed)
-	// dist := distuv.NewCategorical(weights, source)
-	// for i := 0; i < numSimulations; i++ {
-	// 	r := dist.Rand()
-	// 	toCounter[i] = r + 1 // we use plus one here, since the categorical distrubution is zeo indexed
-	// }
-
-	// cp := countr.Count(toCounter)
-
-	// fmt.Println(cp)
-
+	th := classifr.DefaultThresholds
+	th.Seed = randSeed
+	classifications, err := classifr.Classify(pRecords, model, th)
+	if err != nil {
+		panic(err)
+	}
+	for ticker, c := range classifications {
+		fmt.Printf("\t%5s: %-14s (p = %5.3f over %3d periods)\n", ticker, c.Category, c.PValue, c.Lifespan)
+	}
 }
@@ -0,0 +1,306 @@
+package auditr
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/simulatr"
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+var (
+	NotFittedError        error = errors.New("the product has no decile model; fit it with modelr.Fit first")
+	NoExtremeDecilesError error = errors.New("the low and high deciles must be provided")
+	InvalidLifespanError  error = errors.New("the minimum lifespan must not be negative")
+	DecileOutOfModelError error = errors.New("the low and high deciles must be between 1 and the number of deciles in the product's model")
+)
+
+// Side is the end of a product's price range a customer keeps buying at
+type Side int
+
+const (
+	// Low prices, e.g. unusually generous discounts
+	Low Side = iota
+	// High prices, e.g. overcharging
+	High
+)
+
+func (s Side) String() string {
+	if s == High {
+		return "high"
+	}
+	return "low"
+}
+
+// Thresholds hold everything which decides whether a customer is flagged
+type Thresholds struct {
+	LowDeciles  []int8  // deciles which count as extremely low prices
+	HighDeciles []int8  // deciles which count as extremely high prices
+	Alpha       float64 // the p-value below which a customer is flagged
+	MinLifespan int     // customers with fewer purchases are never flagged
+	Trials      int     // the number of simulated lifespans in each null distribution
+}
+
+// DefaultThresholds flag customers who buy in the bottom or top decile more often than the
+// product's own decile model allows, at 95% confidence
+var DefaultThresholds Thresholds = Thresholds{
+	LowDeciles:  []int8{1},
+	HighDeciles: []int8{10},
+	Alpha:       0.05,
+	MinLifespan: 3,
+	Trials:      10000,
+}
+
+func (th Thresholds) validate() error {
+	if th.Alpha <= 0 || th.Alpha >= 1 {
+		return simulatr.InvalidAlphaError
+	}
+	if len(th.LowDeciles) == 0 || len(th.HighDeciles) == 0 {
+		return NoExtremeDecilesError
+	}
+	if th.Trials < 1 {
+		return simulatr.InvalidTrialsError
+	}
+	if th.MinLifespan < 0 {
+		return InvalidLifespanError
+	}
+	return nil
+}
+
+// checkDeciles makes sure the low and high deciles exist in a model of n deciles
+func (th Thresholds) checkDeciles(n int) error {
+	for _, deciles := range [][]int8{th.LowDeciles, th.HighDeciles} {
+		for _, d := range deciles {
+			if d < 1 || int(d) > n {
+				return DecileOutOfModelError
+			}
+		}
+	}
+	return nil
+}
+
+// Document points at a single purchase behind a Finding
+type Document struct {
+	DocumentNumber     int64
+	DocumentLineNumber int64
+	Period             time.Time
+	Price              float64
+	Decile             int8
+}
+
+// Finding is the evidence against a single customer's prices for a single product
+type Finding struct {
+	ProductID  string
+	CustomerID string
+	Side       Side
+	PValue     float64 // the share of simulated customers with at least as many extreme purchases
+	Start      int8    // the decile of the customer's first purchase, where simulations start
+	Lifespan   int     // the number of purchases with an assigned decile
+	Observed   float64 // purchases in the extreme deciles
+	Expected   float64 // the mean of Observed across simulated customers
+	Documents  []Document
+}
+
+// Excess is the number of extreme purchases beyond what the product's model expects
+func (f *Finding) Excess() float64 {
+	return f.Observed - f.Expected
+}
+
+// Report holds the flagged customers, most suspicious first once sorted
+type Report []*Finding
+
+func (r Report) Len() int      { return len(r) }
+func (r Report) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+
+// Less ranks by p-value, then by excess extreme purchases, then by product and customer
+func (r Report) Less(i, j int) bool {
+	a, b := r[i], r[j]
+	switch {
+	case a.PValue != b.PValue:
+		return a.PValue < b.PValue
+	case a.Excess() != b.Excess():
+		return a.Excess() > b.Excess()
+	case a.ProductID != b.ProductID:
+		return a.ProductID < b.ProductID
+	default:
+		return a.CustomerID < b.CustomerID
+	}
+}
+
+// WriteCSV writes one row per finding, in report order, listing its documents as
+// "number/line" separated by spaces
+func (r Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"rank", "productId", "customerId", "side", "pValue", "purchases", "extremePurchases", "expected", "documents"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for i, f := range r {
+		docs := make([]string, len(f.Documents))
+		for j, d := range f.Documents {
+			docs[j] = fmt.Sprintf("%d/%d", d.DocumentNumber, d.DocumentLineNumber)
+		}
+		row := []string{
+			strconv.Itoa(i + 1),
+			f.ProductID,
+			f.CustomerID,
+			f.Side.String(),
+			strconv.FormatFloat(f.PValue, 'f', 4, 64),
+			strconv.Itoa(f.Lifespan),
+			strconv.FormatFloat(f.Observed, 'f', 0, 64),
+			strconv.FormatFloat(f.Expected, 'f', 2, 64),
+			strings.Join(docs, " "),
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// productAuditor compares customers of a single product against simulated customers whose
+// every purchase is drawn from the product's DecileModel
+type productAuditor struct {
+	product    *structs.ProductContainer
+	thresholds Thresholds
+	nulls      *simulatr.Nulls
+}
+
+func newProductAuditor(p *structs.ProductContainer, th Thresholds) (*productAuditor, error) {
+	if p.Deciles.N() == 0 || p.DecileModel.Len() == 0 {
+		return nil, NotFittedError
+	}
+	if err := th.checkDeciles(p.DecileModel.Len()); err != nil {
+		return nil, err
+	}
+	nulls, err := simulatr.NewNulls(simulatr.Independent(p.DecileModel), th.Trials, p.Seed)
+	if err != nil {
+		return nil, err
+	}
+	return &productAuditor{product: p, thresholds: th, nulls: nulls}, nil
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// audit tests one customer at both ends of the price range, returning a Finding for each
+// side the customer is flagged on. Purchases without an assigned decile are ignored.
+func (a *productAuditor) audit(c *structs.CustomerContainer) ([]*Finding, error) {
+	th := a.thresholds
+
+	sorted := make(structs.PriceRecords, len(c.PriceRecords))
+	copy(sorted, c.PriceRecords)
+	sort.Stable(sorted)
+
+	var start int8
+	var lifespan int
+	var low, high []Document
+	for _, pr := range sorted {
+		if pr.Bucket <= 0 {
+			continue
+		}
+		if lifespan == 0 {
+			start = pr.Bucket
+		}
+		lifespan++
+
+		doc := Document{
+			DocumentNumber:     pr.DocumentNumber,
+			DocumentLineNumber: pr.DocumentLineNumber,
+			Period:             pr.Period,
+			Price:              pr.Value,
+			Decile:             pr.Bucket,
+		}
+		if simulatr.InDeciles(th.LowDeciles, pr.Bucket) {
+			low = append(low, doc)
+		}
+		if simulatr.InDeciles(th.HighDeciles, pr.Bucket) {
+			high = append(high, doc)
+		}
+	}
+
+	if lifespan == 0 || lifespan < th.MinLifespan {
+		return nil, nil
+	}
+	null, err := a.nulls.Get(start, lifespan)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*Finding
+	for _, side := range []struct {
+		side    Side
+		deciles []int8
+		docs    []Document
+	}{{Low, th.LowDeciles, low}, {High, th.HighDeciles, high}} {
+		if len(side.docs) == 0 {
+			continue
+		}
+		observed := float64(len(side.docs))
+		pValue, significant, err := null.Significant(observed, th.Alpha, side.deciles...)
+		if err != nil {
+			return nil, err
+		}
+		if !significant {
+			continue
+		}
+		findings = append(findings, &Finding{
+			ProductID:  a.product.ProductID,
+			CustomerID: c.CustomerID,
+			Side:       side.side,
+			PValue:     pValue,
+			Start:      start,
+			Lifespan:   lifespan,
+			Observed:   observed,
+			Expected:   mean(null.Periods(side.deciles...)),
+			Documents:  side.docs,
+		})
+	}
+	return findings, nil
+}
+
+// Audit flags, per product, the customers whose purchases sit in the product's extreme deciles
+// more persistently than the product's DecileModel predicts for a customer of the same lifespan.
+// Products must already be fitted with modelr.Fit, which assigns deciles and stores the
+// DecileModel and the seed simulations are derived from. The report is ranked, most suspicious first.
+// A customer's own purchases are part of their product's model, so a customer making up most of
+// a product's sales will mostly be compared against themselves.
+func Audit(products []structs.ProductContainer, th Thresholds) (Report, error) {
+	if err := th.validate(); err != nil {
+		return nil, err
+	}
+
+	var report Report
+	for i := range products {
+		p := &products[i]
+		a, err := newProductAuditor(p, th)
+		if err != nil {
+			return nil, fmt.Errorf("product %s: %w", p.ProductID, err)
+		}
+		for j := range p.CustomerRecords {
+			findings, err := a.audit(&p.CustomerRecords[j])
+			if err != nil {
+				return nil, fmt.Errorf("product %s: %w", p.ProductID, err)
+			}
+			report = append(report, findings...)
+		}
+	}
+
+	sort.Sort(report)
+	return report, nil
+}
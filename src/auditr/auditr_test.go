@@ -0,0 +1,182 @@
+package auditr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/modelr"
+	"github.com/Viking2012/goraynor/src/simulatr"
+	"github.com/Viking2012/goraynor/src/structs"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// testProducts has one product bought by 50 customers whose prices wander across the whole
+// range, and two more customers who always buy at the very bottom or the very top of it
+func testProducts(t *testing.T) []structs.ProductContainer {
+	p := structs.ProductContainer{ProductID: "bed_bath_table:8"}
+	add := func(customer string, price func(i int) float64) {
+		c := structs.CustomerContainer{CustomerID: customer}
+		for i := 0; i < 12; i++ {
+			c.PriceRecords = append(c.PriceRecords, structs.PriceRecord{
+				ProductID:          p.ProductID,
+				CustomerID:         customer,
+				DocumentNumber:     int64(100000 + len(p.CustomerRecords)*100 + i),
+				DocumentLineNumber: 1,
+				Period:             time.Date(2017, time.January, i+1, 0, 0, 0, 0, time.UTC),
+				Value:              price(i),
+			})
+		}
+		p.CustomerRecords = append(p.CustomerRecords, c)
+	}
+
+	for c := 0; c < 50; c++ {
+		c := c
+		add(fmt.Sprintf("c%02d", c), func(i int) float64 { return float64(100 + (c*7+i*13)%40) })
+	}
+	add("cheap", func(i int) float64 { return 90 })
+	add("dear", func(i int) float64 { return 150 })
+
+	products := []structs.ProductContainer{p}
+	if err := modelr.FitAll(products, modelr.DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+	return products
+}
+
+func TestAuditFlagsPersistentExtremes(t *testing.T) {
+	th := DefaultThresholds
+	th.Trials = 2000
+	report, err := Audit(testProducts(t), th)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flagged := make(map[string]*Finding)
+	for _, f := range report {
+		flagged[f.CustomerID] = f
+	}
+	if f, ok := flagged["cheap"]; !ok || f.Side != Low {
+		t.Errorf("The customer always buying at the lowest price should have been flagged low, but got %+v", f)
+	}
+	if f, ok := flagged["dear"]; !ok || f.Side != High {
+		t.Errorf("The customer always buying at the highest price should have been flagged high, but got %+v", f)
+	}
+	if len(report) > 4 {
+		t.Errorf("Few of the wandering customers should have been flagged, but %d customers were", len(report))
+	}
+
+	f := flagged["cheap"]
+	if f != nil {
+		if f.Observed != 12 || f.Lifespan != 12 || len(f.Documents) != 12 {
+			t.Errorf("All 12 of cheap's purchases should be in evidence, but got %+v", f)
+		}
+		if f.Expected >= f.Observed {
+			t.Errorf("The model should expect fewer extreme purchases than observed, but expected %f", f.Expected)
+		}
+	}
+
+	for i := 1; i < len(report); i++ {
+		if report.Less(i, i-1) {
+			t.Errorf("The report should be ranked, but finding %d ranks above finding %d", i, i-1)
+		}
+	}
+}
+
+func TestAuditIsReproducible(t *testing.T) {
+	th := DefaultThresholds
+	th.Trials = 500
+	a, err := Audit(testProducts(t), th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Audit(testProducts(t), th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != len(b) {
+		t.Fatalf("Audits of the same data should agree, but found %d and %d customers", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].CustomerID != b[i].CustomerID || a[i].PValue != b[i].PValue {
+			t.Errorf("For rank %d, the audits disagree: %+v and %+v", i+1, a[i], b[i])
+		}
+	}
+}
+
+func TestAuditUsesTheProductsDecileModel(t *testing.T) {
+	products := testProducts(t)
+	// a model in which every purchase is in the top decile leaves nothing unusual about "dear"
+	weights := make([]float64, 10)
+	weights[9] = 1
+	products[0].DecileModel = distuv.NewCategorical(weights, nil)
+
+	th := DefaultThresholds
+	th.Trials = 500
+	report, err := Audit(products, th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range report {
+		if f.CustomerID == "dear" {
+			t.Errorf("Under a model of only top-decile purchases, dear should not have been flagged, but got %+v", f)
+		}
+	}
+}
+
+func TestAuditErrorsOnUnfittedProduct(t *testing.T) {
+	_, err := Audit([]structs.ProductContainer{{ProductID: "unfitted"}}, DefaultThresholds)
+	if !errors.Is(err, NotFittedError) {
+		t.Errorf("Audit should have returned NotFittedError for an unfitted product, but got %v", err)
+	}
+}
+
+func TestAuditValidatesThresholds(t *testing.T) {
+	products := testProducts(t)
+
+	type testCase struct {
+		Modify func(th *Thresholds)
+		Want   error
+	}
+	var testCases []testCase = []testCase{
+		{Modify: func(th *Thresholds) { th.Alpha = 0 }, Want: simulatr.InvalidAlphaError},
+		{Modify: func(th *Thresholds) { th.LowDeciles = nil }, Want: NoExtremeDecilesError},
+		{Modify: func(th *Thresholds) { th.Trials = 0 }, Want: simulatr.InvalidTrialsError},
+		{Modify: func(th *Thresholds) { th.MinLifespan = -1 }, Want: InvalidLifespanError},
+		{Modify: func(th *Thresholds) { th.LowDeciles = []int8{0} }, Want: DecileOutOfModelError},
+		{Modify: func(th *Thresholds) { th.HighDeciles = []int8{11} }, Want: DecileOutOfModelError},
+	}
+
+	for i, tc := range testCases {
+		th := DefaultThresholds
+		th.Trials = 10
+		tc.Modify(&th)
+		if _, err := Audit(products, th); !errors.Is(err, tc.Want) {
+			t.Errorf("For test case %d, wanted error %v, but got %v", i, tc.Want, err)
+		}
+	}
+}
+
+func TestReportWriteCSV(t *testing.T) {
+	report := Report{{
+		ProductID:  "bed_bath_table:8",
+		CustomerID: "cheap",
+		Side:       Low,
+		PValue:     0.0005,
+		Lifespan:   2,
+		Observed:   2,
+		Expected:   0.2,
+		Documents:  []Document{{DocumentNumber: 100000, DocumentLineNumber: 1}, {DocumentNumber: 100001, DocumentLineNumber: 2}},
+	}}
+
+	var b strings.Builder
+	if err := report.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "1,bed_bath_table:8,cheap,low,0.0005,2,2,0.20,100000/1 100001/2\n"
+	if lines := strings.SplitAfter(b.String(), "\n"); len(lines) < 2 || lines[1] != want {
+		t.Errorf("WriteCSV should have written the row %q, but wrote:\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,213 @@
+package classifr
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/Viking2012/goraynor/src/simulatr"
+	"github.com/Viking2012/goraynor/src/structs"
+	"github.com/Viking2012/goraynor/src/transitions"
+)
+
+var (
+	NoTopDecilesError     error = errors.New("the deciles counted as top performance must be provided")
+	InvalidLifespanError  error = errors.New("the minimum lifespan must not be negative")
+	DecileOutOfModelError error = errors.New("the top deciles must be between 1 and the number of deciles in the model")
+)
+
+// Category is one of Raynor and Ahmed's three performance categories
+type Category int
+
+const (
+	// AverageJoe performance is indistinguishable from luck
+	AverageJoe Category = iota
+	// LongRunner performance stays in the upper deciles more often than luck would allow
+	LongRunner
+	// MiracleWorker performance stays in the very top deciles more often than luck would allow
+	MiracleWorker
+)
+
+func (c Category) String() string {
+	switch c {
+	case MiracleWorker:
+		return "Miracle Worker"
+	case LongRunner:
+		return "Long Runner"
+	default:
+		return "Average Joe"
+	}
+}
+
+// Thresholds hold everything which decides how an entity is classified
+type Thresholds struct {
+	MiracleDeciles    []int8  // deciles which count as Miracle Worker performance
+	LongRunnerDeciles []int8  // deciles which count as Long Runner performance
+	Alpha             float64 // the p-value below which performance is not put down to luck
+	MinLifespan       int     // entities with fewer assigned periods are always Average Joes
+	Trials            int     // the number of simulated lifespans in each null distribution
+	Seed              uint64  // the base seed of every simulation
+}
+
+// DefaultThresholds follows Raynor and Ahmed: top decile performance for Miracle Workers,
+// top half performance for Long Runners, both at 90% confidence
+var DefaultThresholds Thresholds = Thresholds{
+	MiracleDeciles:    []int8{10},
+	LongRunnerDeciles: []int8{6, 7, 8, 9, 10},
+	Alpha:             0.10,
+	MinLifespan:       1,
+	Trials:            10000,
+	Seed:              123456,
+}
+
+// validate checks the thresholds against a model of n deciles
+func (th Thresholds) validate(n int) error {
+	if th.Alpha <= 0 || th.Alpha >= 1 {
+		return simulatr.InvalidAlphaError
+	}
+	if len(th.MiracleDeciles) == 0 || len(th.LongRunnerDeciles) == 0 {
+		return NoTopDecilesError
+	}
+	for _, deciles := range [][]int8{th.MiracleDeciles, th.LongRunnerDeciles} {
+		for _, d := range deciles {
+			if d < 1 || int(d) > n {
+				return DecileOutOfModelError
+			}
+		}
+	}
+	if th.Trials < 1 {
+		return simulatr.InvalidTrialsError
+	}
+	if th.MinLifespan < 0 {
+		return InvalidLifespanError
+	}
+	return nil
+}
+
+// Classification is the verdict for a single entity, along with the evidence behind it
+type Classification struct {
+	Category          Category
+	PValue            float64 // p-value of the assigned category (the Long Runner test for Average Joes)
+	Start             int8    // the decile of the first assigned period
+	Lifespan          int     // the number of periods with an assigned decile
+	MiraclePeriods    float64 // observed periods in Thresholds.MiracleDeciles
+	MiraclePValue     float64
+	LongRunnerPeriods float64 // observed periods in Thresholds.LongRunnerDeciles
+	LongRunnerPValue  float64
+	Thresholds        Thresholds
+}
+
+// Classifications maps each classified entity, e.g. a ticker, to its Classification
+type Classifications map[string]*Classification
+
+// Classifier compares entities against simulated null distributions from a single
+// transition model. Null distributions are cached per starting decile and lifespan.
+type Classifier struct {
+	thresholds Thresholds
+	nulls      *simulatr.Nulls
+}
+
+// NewClassifier returns a Classifier for the row-stochastic transition matrix model
+func NewClassifier(model transitions.Matrix, th Thresholds) (*Classifier, error) {
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	if err := th.validate(len(model)); err != nil {
+		return nil, err
+	}
+	nulls, err := simulatr.NewNulls(model, th.Trials, th.Seed)
+	if err != nil {
+		return nil, err
+	}
+	return &Classifier{thresholds: th, nulls: nulls}, nil
+}
+
+func countIn(deciles []int8, d int8) float64 {
+	if simulatr.InDeciles(deciles, d) {
+		return 1
+	}
+	return 0
+}
+
+// Classify labels a single entity from its chronological records.
+// Records without an assigned decile are ignored.
+func (c *Classifier) Classify(records structs.PriceRecords) (*Classification, error) {
+	th := c.thresholds
+	result := &Classification{
+		Category:         AverageJoe,
+		PValue:           1,
+		MiraclePValue:    1,
+		LongRunnerPValue: 1,
+		Thresholds:       th,
+	}
+
+	sorted := make(structs.PriceRecords, len(records))
+	copy(sorted, records)
+	sort.Stable(sorted)
+
+	for _, pr := range sorted {
+		if pr.Bucket <= 0 {
+			continue
+		}
+		if result.Lifespan == 0 {
+			result.Start = pr.Bucket
+		}
+		result.Lifespan++
+		result.MiraclePeriods += countIn(th.MiracleDeciles, pr.Bucket)
+		result.LongRunnerPeriods += countIn(th.LongRunnerDeciles, pr.Bucket)
+	}
+
+	if result.Lifespan == 0 || result.Lifespan < th.MinLifespan {
+		return result, nil
+	}
+
+	null, err := c.nulls.Get(result.Start, result.Lifespan)
+	if err != nil {
+		return nil, err
+	}
+	var miracle, longRunner bool
+	if result.MiraclePValue, miracle, err = null.Significant(result.MiraclePeriods, th.Alpha, th.MiracleDeciles...); err != nil {
+		return nil, err
+	}
+	if result.LongRunnerPValue, longRunner, err = null.Significant(result.LongRunnerPeriods, th.Alpha, th.LongRunnerDeciles...); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case miracle:
+		result.Category = MiracleWorker
+		result.PValue = result.MiraclePValue
+	case longRunner:
+		result.Category = LongRunner
+		result.PValue = result.LongRunnerPValue
+	default:
+		result.PValue = result.LongRunnerPValue
+	}
+
+	return result, nil
+}
+
+// ClassifyAll labels every entity. Deciles must already be assigned, e.g. with AllPerformers.SetDeciles.
+func (c *Classifier) ClassifyAll(ap *structs.AllPerformers) (Classifications, error) {
+	classifications := make(Classifications, len(*ap))
+	for entity, records := range *ap {
+		var toClassify structs.PriceRecords
+		if records != nil {
+			toClassify = *records
+		}
+		result, err := c.Classify(toClassify)
+		if err != nil {
+			return nil, err
+		}
+		classifications[entity] = result
+	}
+	return classifications, nil
+}
+
+// Classify labels every entity against the row-stochastic transition matrix model
+func Classify(ap *structs.AllPerformers, model transitions.Matrix, th Thresholds) (Classifications, error) {
+	c, err := NewClassifier(model, th)
+	if err != nil {
+		return nil, err
+	}
+	return c.ClassifyAll(ap)
+}
@@ -0,0 +1,168 @@
+package classifr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/simulatr"
+	"github.com/Viking2012/goraynor/src/structs"
+	"github.com/Viking2012/goraynor/src/transitions"
+)
+
+func month(m int) time.Time {
+	return time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, m, 0)
+}
+
+// sequence builds monthly records by repeating the deciles given until the lifespan is reached
+func sequence(lifespan int, deciles ...int8) *structs.PriceRecords {
+	records := make(structs.PriceRecords, lifespan)
+	for i := 0; i < lifespan; i++ {
+		records[i] = structs.PriceRecord{Period: month(i), Bucket: deciles[i%len(deciles)]}
+	}
+	return &records
+}
+
+// uniformModel is a null model where every decile is equally likely in every period
+func uniformModel() transitions.Matrix {
+	m, _ := transitions.NewMatrix(10)
+	p, _ := m.Normalize(transitions.UniformRow)
+	return p
+}
+
+var testThresholds Thresholds = Thresholds{
+	MiracleDeciles:    []int8{10},
+	LongRunnerDeciles: []int8{6, 7, 8, 9, 10},
+	Alpha:             0.10,
+	MinLifespan:       1,
+	Trials:            2000,
+	Seed:              42,
+}
+
+func TestClassifyAll(t *testing.T) {
+	ap := structs.AllPerformers{
+		"MIRACLE": sequence(24, 10),
+		"RUNNER":  sequence(24, 6, 7, 8, 9),
+		"AVERAGE": sequence(24, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+		"EMPTY":   sequence(3, 0),
+	}
+
+	got, err := Classify(&ap, uniformModel(), testThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Category{
+		"MIRACLE": MiracleWorker,
+		"RUNNER":  LongRunner,
+		"AVERAGE": AverageJoe,
+		"EMPTY":   AverageJoe,
+	}
+	if len(got) != len(want) {
+		t.Errorf("Classify should have returned %d classifications, but got %d", len(want), len(got))
+	}
+	for entity, w := range want {
+		g, ok := got[entity]
+		if !ok {
+			t.Errorf("Classify did not return a classification for %s", entity)
+			continue
+		}
+		if g.Category != w {
+			t.Errorf("For entity %s, wanted %s but got %s (p-value %f)", entity, w, g.Category, g.PValue)
+		}
+	}
+
+	miracle := got["MIRACLE"]
+	if miracle.Lifespan != 24 || miracle.Start != 10 || miracle.MiraclePeriods != 24 {
+		t.Errorf("Miracle worker evidence should have been 24 of 24 periods starting in decile 10, but got %+v", miracle)
+	}
+	if miracle.PValue >= testThresholds.Alpha || miracle.PValue != miracle.MiraclePValue {
+		t.Errorf("Miracle worker should report its significant miracle p-value, but got %f", miracle.PValue)
+	}
+	if got["EMPTY"].Lifespan != 0 || got["EMPTY"].PValue != 1 {
+		t.Errorf("An entity without assigned deciles should have no lifespan and a p-value of 1, but got %+v", got["EMPTY"])
+	}
+}
+
+func TestClassifyRespectsMinLifespan(t *testing.T) {
+	th := testThresholds
+	th.MinLifespan = 30
+
+	c, err := NewClassifier(uniformModel(), th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Classify(*sequence(24, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Category != AverageJoe {
+		t.Errorf("An entity shorter than the minimum lifespan should be an Average Joe, but got %s", got.Category)
+	}
+}
+
+func TestClassifyIsReproducible(t *testing.T) {
+	records := *sequence(12, 9, 10, 4)
+
+	a, _ := NewClassifier(uniformModel(), testThresholds)
+	b, _ := NewClassifier(uniformModel(), testThresholds)
+	// warm b's cache with another lifespan first, so the order of simulation differs
+	_, _ = b.Classify(*sequence(7, 10))
+
+	ga, _ := a.Classify(records)
+	gb, _ := b.Classify(records)
+	if ga.MiraclePValue != gb.MiraclePValue || ga.LongRunnerPValue != gb.LongRunnerPValue {
+		t.Errorf("The same thresholds should give the same p-values, but got %+v and %+v", ga, gb)
+	}
+}
+
+func TestNewClassifierValidatesThresholds(t *testing.T) {
+	type testCase struct {
+		Modify func(th *Thresholds)
+		Want   error
+	}
+	var testCases []testCase = []testCase{
+		{Modify: func(th *Thresholds) { th.Alpha = 0 }, Want: simulatr.InvalidAlphaError},
+		{Modify: func(th *Thresholds) { th.Alpha = 1 }, Want: simulatr.InvalidAlphaError},
+		{Modify: func(th *Thresholds) { th.MiracleDeciles = nil }, Want: NoTopDecilesError},
+		{Modify: func(th *Thresholds) { th.Trials = 0 }, Want: simulatr.InvalidTrialsError},
+		{Modify: func(th *Thresholds) { th.MinLifespan = -1 }, Want: InvalidLifespanError},
+		{Modify: func(th *Thresholds) { th.MiracleDeciles = []int8{11} }, Want: DecileOutOfModelError},
+		{Modify: func(th *Thresholds) { th.LongRunnerDeciles = []int8{0, 10} }, Want: DecileOutOfModelError},
+	}
+
+	for i, tc := range testCases {
+		th := testThresholds
+		tc.Modify(&th)
+		_, err := NewClassifier(uniformModel(), th)
+		if err != tc.Want {
+			t.Errorf("For test case %d, wanted error %v, but got %v", i, tc.Want, err)
+		}
+	}
+}
+
+func TestNewClassifierChecksDecilesAgainstTheModel(t *testing.T) {
+	m, _ := transitions.NewMatrix(5)
+	model, _ := m.Normalize(transitions.UniformRow)
+	if _, err := NewClassifier(model, testThresholds); err != DecileOutOfModelError {
+		t.Errorf("For a model of 5 deciles and the top decile 10, wanted %v, but got %v", DecileOutOfModelError, err)
+	}
+
+	th := testThresholds
+	th.MiracleDeciles, th.LongRunnerDeciles = []int8{5}, []int8{4, 5}
+	if _, err := NewClassifier(model, th); err != nil {
+		t.Errorf("For top deciles within a model of 5, wanted no error, but got %v", err)
+	}
+}
+
+func TestCategoryString(t *testing.T) {
+	want := map[Category]string{
+		MiracleWorker: "Miracle Worker",
+		LongRunner:    "Long Runner",
+		AverageJoe:    "Average Joe",
+	}
+	for c, w := range want {
+		if c.String() != w {
+			t.Errorf("Wanted %s, but got %s", w, c.String())
+		}
+	}
+}
@@ -1,6 +1,15 @@
 package countr
 
-import "sort"
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	WeightsLengthError error = errors.New("every value must have exactly one weight")
+	InvalidWeightError error = errors.New("weights must be finite and not negative")
+)
 
 // CounterPair holds a key, value pair of numbers and counts (basically a map)
 // generally, this is used to hold unique prices and their number of occurances
@@ -36,14 +45,55 @@ func NewCounter(m map[float64]float64) Counter {
 // Count takes an array of floats and returns a Counter of unique values and the
 // number of occurances of these values
 func Count(values []float64) Counter {
-	var tempCounts = make(map[float64]float64)
+	var tempCounts = make(Tally)
 	for _, v := range values {
-		tempCounts[v]++
+		tempCounts.Add(v)
+	}
+
+	return tempCounts.Counter()
+}
+
+// CountWeighted is Count where each value contributes its weight (e.g. a market cap, revenue or
+// quantity) to its Count rather than 1. Values whose weights total 0 are left out.
+func CountWeighted(values, weights []float64) (Counter, error) {
+	if len(values) != len(weights) {
+		return nil, WeightsLengthError
+	}
+
+	var tempCounts = make(Tally)
+	for i, v := range values {
+		if err := tempCounts.AddWeighted(v, weights[i]); err != nil {
+			return nil, err
+		}
+	}
+	for v, w := range tempCounts {
+		if w == 0 {
+			delete(tempCounts, v)
+		}
 	}
 
-	return NewCounter(tempCounts)
+	return tempCounts.Counter(), nil
 }
 
+// Tally accumulates the number of occurances of each value one at a time, so that values
+// can be counted as they stream past instead of being collected into an array first
+type Tally map[float64]float64
+
+// Add counts one more occurance of the value
+func (t Tally) Add(v float64) { t[v]++ }
+
+// AddWeighted counts w more occurances of the value, for weighted counting
+func (t Tally) AddWeighted(v, w float64) error {
+	if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+		return InvalidWeightError
+	}
+	t[v] += w
+	return nil
+}
+
+// Counter converts the tally so far into a sorted Counter
+func (t Tally) Counter() Counter { return NewCounter(t) }
+
 // Returns
 func (c Counter) GetValues() []float64 {
 	var v = make([]float64, c.Len())
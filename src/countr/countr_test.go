@@ -1,6 +1,7 @@
 package countr
 
 import (
+	"math"
 	"sort"
 	"testing"
 )
@@ -133,3 +134,75 @@ func TestGetCounts(t *testing.T) {
 		}
 	}
 }
+
+func TestTally(t *testing.T) {
+	var tally = make(Tally)
+	for _, value := range v {
+		tally.Add(value)
+	}
+
+	got := tally.Counter()
+	want := Count(v)
+	if got.Len() != want.Len() {
+		t.Fatalf("Tally should have counted %d unique values, but got %d", want.Len(), got.Len())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("For index %d, Tally counted %v, but Count returned %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCountWeighted(t *testing.T) {
+	values := []float64{1, 2, 1, 3, 4}
+	weights := []float64{0.5, 10, 1.5, 0, 2}
+	got, err := CountWeighted(values, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Counter{{Value: 1, Count: 2}, {Value: 2, Count: 10}, {Value: 4, Count: 2}}
+	if len(got) != len(want) {
+		t.Fatalf("CountWeighted should have returned %v, but got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("For pair %d, wanted %v, but got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCountWeightedMatchesCountForUnitWeights(t *testing.T) {
+	ones := make([]float64, len(v))
+	for i := range ones {
+		ones[i] = 1
+	}
+	got, err := CountWeighted(v, ones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Count(v)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("For pair %d, wanted %v, but got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCountWeightedRejectsBadWeights(t *testing.T) {
+	type testCase struct {
+		weights []float64
+		want    error
+	}
+	var testCases []testCase = []testCase{
+		{weights: []float64{1}, want: WeightsLengthError},
+		{weights: []float64{1, -1}, want: InvalidWeightError},
+		{weights: []float64{1, math.NaN()}, want: InvalidWeightError},
+		{weights: []float64{math.Inf(1), 1}, want: InvalidWeightError},
+	}
+	for _, tc := range testCases {
+		if _, err := CountWeighted([]float64{1, 2}, tc.weights); err != tc.want {
+			t.Errorf("For weights %v, wanted %v, but got %v", tc.weights, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package countr
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+)
+
+var (
+	InvalidEpsilonError error = errors.New("the sketch's rank error must be between 0 and 1")
+	SketchMismatchError error = errors.New("only sketches built with the same accuracy can be merged")
+	EmptySketchError    error = errors.New("the sketch has not seen any values")
+)
+
+// minSketchK keeps the smallest compactors large enough to be worth compacting
+const minSketchK = 8
+
+// Sketch is a KLL quantile sketch (Karnin, Lang and Liberty, 2016): it summarises a stream of
+// any length in memory which grows only with the log of the stream's length, while estimating
+// the rank of any value to within a fraction epsilon of the number of values seen (with high
+// probability). Sketches of separate streams can be merged, e.g. one per worker.
+// Its Counter can be used wherever a Counter of the full stream would be.
+type Sketch struct {
+	k       int
+	epsilon float64
+	levels  [][]float64 // levels[h] holds values standing for 2^h observations each
+	n       float64
+	min     float64
+	max     float64
+	rng     *rand.Rand
+}
+
+// NewSketch returns an empty sketch whose rank estimates are within epsilon (e.g. 0.01 for one
+// percent of the values seen). Compactions are randomised from seed, so the same values in the
+// same order always give the same sketch.
+func NewSketch(epsilon float64, seed uint64) (*Sketch, error) {
+	if epsilon <= 0 || epsilon >= 1 {
+		return nil, InvalidEpsilonError
+	}
+	// the normalised rank error of KLL is roughly 1.7/k; 2/k leaves some headroom
+	k := int(math.Ceil(2 / epsilon))
+	if k < minSketchK {
+		k = minSketchK
+	}
+	return &Sketch{
+		k:       k,
+		epsilon: epsilon,
+		levels:  make([][]float64, 1),
+		min:     math.Inf(1),
+		max:     math.Inf(-1),
+		rng:     rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// N returns the number of values seen
+func (s *Sketch) N() float64 { return s.n }
+
+// Epsilon returns the rank error the sketch was built for
+func (s *Sketch) Epsilon() float64 { return s.epsilon }
+
+// Min and Max return the exact extremes of the values seen
+func (s *Sketch) Min() float64 { return s.min }
+func (s *Sketch) Max() float64 { return s.max }
+
+// Retained returns the number of values the sketch holds in memory
+func (s *Sketch) Retained() int {
+	var size int
+	for _, level := range s.levels {
+		size += len(level)
+	}
+	return size
+}
+
+// capacity is the number of values level h may hold before it is compacted. Levels shrink
+// geometrically towards the bottom, as their values stand for fewer observations.
+func (s *Sketch) capacity(h int) int {
+	depth := len(s.levels) - 1 - h
+	c := int(math.Ceil(float64(s.k) * math.Pow(2.0/3.0, float64(depth))))
+	if c < 2 {
+		return 2
+	}
+	return c
+}
+
+func (s *Sketch) maxRetained() int {
+	var size int
+	for h := range s.levels {
+		size += s.capacity(h)
+	}
+	return size
+}
+
+// Add counts one more occurance of the value. NaN is ignored.
+func (s *Sketch) Add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	s.levels[0] = append(s.levels[0], v)
+	s.n++
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+
+	for s.Retained() >= s.maxRetained() {
+		s.compact()
+	}
+}
+
+// compact halves the lowest level which is over capacity, promoting every other of its sorted
+// values (starting at random) to the level above, where each stands for twice as many
+func (s *Sketch) compact() {
+	for h := range s.levels {
+		if len(s.levels[h]) < s.capacity(h) {
+			continue
+		}
+		if h+1 == len(s.levels) {
+			s.levels = append(s.levels, nil)
+		}
+
+		items := s.levels[h]
+		sort.Float64s(items)
+		// an odd value out stays behind, so the total weight is unchanged
+		var kept []float64
+		if len(items)%2 == 1 {
+			kept = []float64{items[0]}
+			items = items[1:]
+		}
+		for i := s.rng.Intn(2); i < len(items); i += 2 {
+			s.levels[h+1] = append(s.levels[h+1], items[i])
+		}
+		s.levels[h] = kept
+		return
+	}
+}
+
+// Merge adds everything other has seen into this sketch. Both must have the same epsilon.
+func (s *Sketch) Merge(other *Sketch) error {
+	if other.k != s.k {
+		return SketchMismatchError
+	}
+	for len(s.levels) < len(other.levels) {
+		s.levels = append(s.levels, nil)
+	}
+	for h, level := range other.levels {
+		s.levels[h] = append(s.levels[h], level...)
+	}
+	s.n += other.n
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+
+	for s.Retained() >= s.maxRetained() {
+		s.compact()
+	}
+	return nil
+}
+
+// Counter returns the retained values with the number of observations each stands for, which
+// together count N values. The exact minimum and maximum are always included, so that the
+// lowest and highest quantiles are not estimated.
+func (s *Sketch) Counter() Counter {
+	tally := make(Tally)
+	for h, level := range s.levels {
+		weight := math.Ldexp(1, h)
+		for _, v := range level {
+			tally[v] += weight
+		}
+	}
+	if s.n > 0 {
+		// move a single observation's weight onto each extreme, taken from the nearest values
+		s.pinExtreme(tally, s.min)
+		s.pinExtreme(tally, s.max)
+	}
+	return tally.Counter()
+}
+
+func (s *Sketch) pinExtreme(tally Tally, extreme float64) {
+	if _, ok := tally[extreme]; ok {
+		return
+	}
+	nearest := math.NaN()
+	for v, w := range tally {
+		if w > 1 && (math.IsNaN(nearest) || math.Abs(v-extreme) < math.Abs(nearest-extreme)) {
+			nearest = v
+		}
+	}
+	if math.IsNaN(nearest) {
+		return
+	}
+	tally[nearest]--
+	tally[extreme]++
+}
+
+// Rank estimates the share of values seen which are at or below v
+func (s *Sketch) Rank(v float64) (float64, error) {
+	if s.n == 0 {
+		return 0, EmptySketchError
+	}
+	var below float64
+	for h, level := range s.levels {
+		weight := math.Ldexp(1, h)
+		for _, x := range level {
+			if x <= v {
+				below += weight
+			}
+		}
+	}
+	return below / s.n, nil
+}
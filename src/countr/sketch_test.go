@@ -0,0 +1,141 @@
+package countr
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// shuffled returns 0 to n-1 in a random order, so the true rank of v is (v+1)/n
+func shuffled(n int, seed uint64) []float64 {
+	values := make([]float64, n)
+	for i, p := range rand.New(rand.NewSource(seed)).Perm(n) {
+		values[i] = float64(p)
+	}
+	return values
+}
+
+func maxRankError(t *testing.T, s *Sketch, n int) float64 {
+	var worst float64
+	for _, p := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		v := math.Floor(p * float64(n))
+		got, err := s.Rank(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		worst = math.Max(worst, math.Abs(got-(v+1)/float64(n)))
+	}
+	return worst
+}
+
+func TestSketchRankWithinEpsilon(t *testing.T) {
+	const n = 200000
+	s, err := NewSketch(0.01, 123456)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range shuffled(n, 1) {
+		s.Add(v)
+	}
+
+	if s.N() != n || s.Min() != 0 || s.Max() != n-1 {
+		t.Errorf("Wanted %d values from 0 to %d, but got %3.0f from %3.0f to %3.0f", n, n-1, s.N(), s.Min(), s.Max())
+	}
+	if worst := maxRankError(t, s, n); worst > s.Epsilon() {
+		t.Errorf("Rank estimates should be within %3.2f, but were off by %f", s.Epsilon(), worst)
+	}
+	if s.Retained() > 2000 {
+		t.Errorf("The sketch should hold far fewer than %d values, but holds %d", n, s.Retained())
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	const n = 100000
+	values := shuffled(n, 2)
+
+	// four workers, each sketching a quarter of the stream
+	var merged *Sketch
+	for w := 0; w < 4; w++ {
+		s, err := NewSketch(0.01, uint64(w))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range values[w*n/4 : (w+1)*n/4] {
+			s.Add(v)
+		}
+		if merged == nil {
+			merged = s
+			continue
+		}
+		if err := merged.Merge(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if merged.N() != n {
+		t.Errorf("The merged sketch should have seen %d values, but saw %3.0f", n, merged.N())
+	}
+	if worst := maxRankError(t, merged, n); worst > merged.Epsilon() {
+		t.Errorf("Merged rank estimates should be within %3.2f, but were off by %f", merged.Epsilon(), worst)
+	}
+
+	other, _ := NewSketch(0.05, 0)
+	if err := merged.Merge(other); err != SketchMismatchError {
+		t.Errorf("Merging sketches of different accuracy should have returned SketchMismatchError, but got %v", err)
+	}
+}
+
+func TestSketchCounter(t *testing.T) {
+	const n = 50000
+	s, _ := NewSketch(0.02, 7)
+	for _, v := range shuffled(n, 3) {
+		s.Add(v)
+	}
+
+	c := s.Counter()
+	if !sort.IsSorted(c) {
+		t.Error("The sketch's Counter should be sorted by value")
+	}
+	var total float64
+	for _, pair := range c {
+		total += pair.Count
+	}
+	if total != n {
+		t.Errorf("The sketch's Counter should count all %d values, but counts %3.0f", n, total)
+	}
+	if c[0].Value != 0 || c[len(c)-1].Value != n-1 {
+		t.Errorf("The sketch's Counter should hold the exact extremes, but runs from %3.0f to %3.0f", c[0].Value, c[len(c)-1].Value)
+	}
+}
+
+func TestSketchIsReproducible(t *testing.T) {
+	a, _ := NewSketch(0.05, 99)
+	b, _ := NewSketch(0.05, 99)
+	for _, v := range shuffled(10000, 4) {
+		a.Add(v)
+		b.Add(v)
+	}
+	ca, cb := a.Counter(), b.Counter()
+	if len(ca) != len(cb) {
+		t.Fatalf("The same seed and values should give the same sketch, but got %d and %d values", len(ca), len(cb))
+	}
+	for i := range ca {
+		if ca[i] != cb[i] {
+			t.Fatalf("The same seed and values should give the same sketch, but index %d was %v and %v", i, ca[i], cb[i])
+		}
+	}
+}
+
+func TestNewSketchRejectsBadEpsilon(t *testing.T) {
+	for _, eps := range []float64{0, -0.1, 1} {
+		if _, err := NewSketch(eps, 0); err != InvalidEpsilonError {
+			t.Errorf("For epsilon %3.1f, wanted an InvalidEpsilonError, but got %v", eps, err)
+		}
+	}
+	s, _ := NewSketch(0.1, 0)
+	if _, err := s.Rank(1); err != EmptySketchError {
+		t.Errorf("An empty sketch should have returned EmptySketchError, but got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package getr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+const manifestName = "manifest.json"
+
+var NotCachedError error = errors.New("the ticker has not been downloaded into the cache")
+
+// CacheEntry records what the cache holds for a single ticker
+type CacheEntry struct {
+	First   time.Time `json:"first"`   // the date of the earliest cached bar
+	Last    time.Time `json:"last"`    // the date of the latest cached bar
+	Bars    int       `json:"bars"`    // the number of cached bars
+	Updated time.Time `json:"updated"` // when the ticker was last fetched
+}
+
+// Manifest maps each cached ticker to what the cache holds for it
+type Manifest map[string]CacheEntry
+
+// Cache keeps the full price history of each ticker in <Dir>/<ticker>.json, with a manifest
+// of the last bar date per ticker so that updates only fetch what is new. Updates start again
+// from the beginning of the last cached period, so that a provisional bar for a period still in
+// progress is replaced once the period is complete. Bars before it are never refetched, so
+// their adjusted closes are not revised.
+type Cache struct {
+	Dir      string
+	Period   structs.PeriodFunc // the period each bar covers, e.g. structs.ByMonth; nil is structs.ByDate
+	mu       sync.Mutex
+	manifest Manifest
+}
+
+// OpenCache opens the cache in dir, creating the directory if it does not exist yet
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{Dir: dir, manifest: make(Manifest)}
+	in, err := os.Open(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	if err := json.NewDecoder(in).Decode(&c.manifest); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Manifest returns a copy of the cache's manifest
+func (c *Cache) Manifest() Manifest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(Manifest, len(c.manifest))
+	for ticker, entry := range c.manifest {
+		m[ticker] = entry
+	}
+	return m
+}
+
+func (c *Cache) period() structs.PeriodFunc {
+	if c.Period == nil {
+		return structs.ByDate
+	}
+	return c.Period
+}
+
+func (c *Cache) filename(ticker string) string {
+	return filepath.Join(c.Dir, ticker+".json")
+}
+
+// mergeBars combines the cached and fetched bars into one chronological history with one bar
+// per period. Where both have a bar for the same period, the fetched bar wins.
+func mergeBars(cached, fetched []Bar, period structs.PeriodFunc) []Bar {
+	byPeriod := make(map[int64]Bar, len(cached)+len(fetched))
+	for _, b := range cached {
+		byPeriod[period(b.Date).Unix()] = b
+	}
+	for _, b := range fetched {
+		byPeriod[period(b.Date).Unix()] = b
+	}
+
+	merged := make([]Bar, 0, len(byPeriod))
+	for _, b := range byPeriod {
+		merged = append(merged, b)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Date.Before(merged[j].Date) })
+	return merged
+}
+
+// store merges newly fetched bars into a ticker's cached history and updates its manifest entry
+func (c *Cache) store(ticker string, fetched []Bar, fetchedAt time.Time) error {
+	cached, err := readBars(c.filename(ticker))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	merged := mergeBars(cached, fetched, c.period())
+	if err := writeJSONAtomic(c.filename(ticker), merged); err != nil {
+		return err
+	}
+
+	entry := CacheEntry{Bars: len(merged), Updated: fetchedAt}
+	if len(merged) > 0 {
+		entry.First = merged[0].Date
+		entry.Last = merged[len(merged)-1].Date
+	}
+
+	c.mu.Lock()
+	c.manifest[ticker] = entry
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Cache) saveManifest() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeJSONAtomic(filepath.Join(c.Dir, manifestName), c.manifest)
+}
+
+// Update fetches every ticker through the manager, asking only for bars from the start of the
+// last cached period on, and merges them into the cache. The manifest is saved even if some tickers failed.
+func (c *Cache) Update(ctx context.Context, m *Manager, tickers []string, now time.Time) (Report, error) {
+	manifest := c.Manifest()
+	jobs := make([]Job, len(tickers))
+	for i, ticker := range tickers {
+		jobs[i] = Job{Ticker: ticker, End: now}
+		if entry, ok := manifest[ticker]; ok && !entry.Last.IsZero() {
+			jobs[i].Start = c.period()(entry.Last)
+		}
+	}
+
+	report := m.Run(ctx, jobs, func(ticker string, bars []Bar) error {
+		return c.store(ticker, bars, now)
+	})
+	if err := c.saveManifest(); err != nil {
+		return report, err
+	}
+	return report, report.Err()
+}
+
+// Bars returns the cached bars of a ticker dated on or before asOf, so that a run can be
+// repeated against the data it originally saw. A zero asOf returns every cached bar.
+func (c *Cache) Bars(ticker string, asOf time.Time) ([]Bar, error) {
+	bars, err := readBars(c.filename(ticker))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, NotCachedError
+	}
+	if err != nil {
+		return nil, err
+	}
+	if asOf.IsZero() {
+		return bars, nil
+	}
+
+	var snapshot []Bar
+	for _, b := range bars {
+		if !b.Date.After(asOf) {
+			snapshot = append(snapshot, b)
+		}
+	}
+	return snapshot, nil
+}
+
+// GetTickers reads each ticker's cached history as of the given date, as GetTickers does for a save directory
+func (c *Cache) GetTickers(tickers []string, asOf time.Time) (*structs.AllPerformers, error) {
+	var allPerf structs.AllPerformers = make(structs.AllPerformers, len(tickers))
+	for _, ticker := range tickers {
+		bars, err := c.Bars(ticker, asOf)
+		if err != nil {
+			return nil, err
+		}
+		records := BarsToRecords(ticker, bars)
+		allPerf[ticker] = &records
+	}
+
+	return &allPerf, nil
+}
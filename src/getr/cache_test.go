@@ -0,0 +1,191 @@
+package getr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func monthEnd(year int, month time.Month) time.Time {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+}
+
+// historyProvider serves the bars of a fixed history which fall within the requested range
+type historyProvider struct {
+	mu      sync.Mutex
+	history []Bar
+	starts  map[string]time.Time
+}
+
+func (p *historyProvider) History(ctx context.Context, ticker string, start, end time.Time) ([]Bar, error) {
+	p.mu.Lock()
+	p.starts[ticker] = start
+	p.mu.Unlock()
+
+	var bars []Bar
+	for _, b := range p.history {
+		if (start.IsZero() || !b.Date.Before(start)) && (end.IsZero() || !b.Date.After(end)) {
+			bars = append(bars, b)
+		}
+	}
+	return bars, nil
+}
+
+func newHistoryProvider() *historyProvider {
+	var history []Bar
+	for m := time.January; m <= time.August; m++ {
+		history = append(history, Bar{Date: monthEnd(2021, m), Close: float64(m), AdjClose: float64(m)})
+	}
+	return &historyProvider{history: history, starts: make(map[string]time.Time)}
+}
+
+func TestCacheUpdatesIncrementally(t *testing.T) {
+	dir := t.TempDir()
+	p := newHistoryProvider()
+	m := newTestManager(p)
+
+	cache, err := OpenCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Period = structs.ByMonth
+	if _, err := cache.Update(context.Background(), m, []string{"AAAAX"}, monthEnd(2021, time.May)); err != nil {
+		t.Fatal(err)
+	}
+	if !p.starts["AAAAX"].IsZero() {
+		t.Errorf("The first update should have requested the full history, but started at %s", p.starts["AAAAX"])
+	}
+
+	// reopen, so the second update has to rely on the saved manifest
+	cache, err = OpenCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Period = structs.ByMonth
+	entry := cache.Manifest()["AAAAX"]
+	if entry.Bars != 5 || !entry.Last.Equal(monthEnd(2021, time.May)) {
+		t.Errorf("Manifest should have recorded 5 bars up to May, but got %+v", entry)
+	}
+
+	if _, err := cache.Update(context.Background(), m, []string{"AAAAX"}, monthEnd(2021, time.August)); err != nil {
+		t.Fatal(err)
+	}
+	wantStart := time.Date(2021, time.May, 1, 0, 0, 0, 0, time.UTC)
+	if !p.starts["AAAAX"].Equal(wantStart) {
+		t.Errorf("The second update should only have requested bars from the start of May, %s, but started at %s", wantStart, p.starts["AAAAX"])
+	}
+
+	bars, err := cache.Bars("AAAAX", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 8 {
+		t.Fatalf("The cache should hold all 8 months after merging, but holds %d", len(bars))
+	}
+	for i := 1; i < len(bars); i++ {
+		if !bars[i-1].Date.Before(bars[i].Date) {
+			t.Errorf("Cached bars should be in strictly chronological order, but bar %d is %s and bar %d is %s", i-1, bars[i-1].Date, i, bars[i].Date)
+		}
+	}
+	if entry := cache.Manifest()["AAAAX"]; entry.Bars != 8 || !entry.Updated.Equal(monthEnd(2021, time.August)) {
+		t.Errorf("Manifest should have recorded 8 bars updated at the end of August, but got %+v", entry)
+	}
+}
+
+func TestCacheAsOfSnapshot(t *testing.T) {
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Update(context.Background(), newTestManager(newHistoryProvider()), []string{"AAAAX"}, monthEnd(2021, time.August)); err != nil {
+		t.Fatal(err)
+	}
+
+	asOf := monthEnd(2021, time.March)
+	bars, err := cache.Bars("AAAAX", asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 3 || !bars[2].Date.Equal(asOf) {
+		t.Errorf("A snapshot as of the end of March should hold January to March, but got %v", bars)
+	}
+
+	ap, err := cache.GetTickers([]string{"AAAAX"}, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*(*ap)["AAAAX"]) != 2 {
+		t.Errorf("Three bars should give two monthly returns, but got %d", len(*(*ap)["AAAAX"]))
+	}
+
+	if _, err := cache.Bars("NOPE", asOf); err != NotCachedError {
+		t.Errorf("Reading a ticker which was never cached should have returned NotCachedError, but got %v", err)
+	}
+}
+
+func TestMergeBarsPrefersFetched(t *testing.T) {
+	cached := []Bar{{Date: monthEnd(2021, time.January), AdjClose: 1}, {Date: monthEnd(2021, time.February), AdjClose: 2}}
+	fetched := []Bar{{Date: monthEnd(2021, time.February), AdjClose: 20}, {Date: monthEnd(2021, time.March), AdjClose: 3}}
+
+	merged := mergeBars(cached, fetched, structs.ByDate)
+	want := []float64{1, 20, 3}
+	if len(merged) != len(want) {
+		t.Fatalf("mergeBars should have returned %d bars, but got %d", len(want), len(merged))
+	}
+	for i, w := range want {
+		if merged[i].AdjClose != w {
+			t.Errorf("For bar %d, wanted adjClose %3.1f, but got %3.1f", i, w, merged[i].AdjClose)
+		}
+	}
+}
+
+func TestCacheReplacesProvisionalBars(t *testing.T) {
+	p := newHistoryProvider()
+	provisional := Bar{Date: time.Date(2021, time.September, 15, 0, 0, 0, 0, time.UTC), Close: 8.5, AdjClose: 8.5}
+	p.history = append(p.history, provisional)
+
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Period = structs.ByMonth
+	m := newTestManager(p)
+	if _, err := cache.Update(context.Background(), m, []string{"AAAAX"}, provisional.Date); err != nil {
+		t.Fatal(err)
+	}
+
+	// September completes, and its bar moves to the end of the month
+	p.history[len(p.history)-1] = Bar{Date: monthEnd(2021, time.September), Close: 9, AdjClose: 9}
+	if _, err := cache.Update(context.Background(), m, []string{"AAAAX"}, monthEnd(2021, time.October)); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC); !p.starts["AAAAX"].Equal(want) {
+		t.Errorf("The second update should have refetched September from %s, but started at %s", want, p.starts["AAAAX"])
+	}
+
+	bars, err := cache.Bars("AAAAX", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 9 {
+		t.Fatalf("The cache should hold one bar for each of 9 months, but holds %d", len(bars))
+	}
+	if last := bars[len(bars)-1]; !last.Date.Equal(monthEnd(2021, time.September)) || last.AdjClose != 9 {
+		t.Errorf("The provisional September bar should have been replaced, but the last bar is %+v", last)
+	}
+}
+
+func TestMergeBarsKeepsOneBarPerPeriod(t *testing.T) {
+	cached := []Bar{{Date: monthEnd(2021, time.January), AdjClose: 1}, {Date: time.Date(2021, time.February, 12, 0, 0, 0, 0, time.UTC), AdjClose: 2}}
+	fetched := []Bar{{Date: monthEnd(2021, time.February), AdjClose: 20}}
+
+	if merged := mergeBars(cached, fetched, structs.ByMonth); len(merged) != 2 || merged[1].AdjClose != 20 {
+		t.Errorf("mergeBars should have replaced the provisional February bar, but got %v", merged)
+	}
+	if merged := mergeBars(cached, fetched, structs.ByDate); len(merged) != 3 {
+		t.Errorf("mergeBars by date should have kept bars with different dates, but got %v", merged)
+	}
+}
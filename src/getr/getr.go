@@ -1,92 +1,68 @@
 package getr
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
-	"github.com/Viking2012/goraynor/data/basis"
 	"github.com/Viking2012/goraynor/src/structs"
 )
 
-const baseUrl = "https://api.tiingo.com/tiingo/daily/"
-const DefaultTimeout = time.Second * 8
-
-type rawTiingoResponse struct {
-	TickerDate string  `json:"date"`
-	Close      float32 `json:"close,float32"`
-	AdjClose   float32 `json:"adjClose"`
-}
-
-func (tR *rawTiingoResponse) calculatePercentChange(previousPrice *rawTiingoResponse) float64 {
-	var pChange float64
-	pChange = float64((tR.AdjClose - previousPrice.AdjClose) / previousPrice.AdjClose)
-	return pChange
+func calculatePercentChange(this, previous *Bar) float64 {
+	return (this.AdjClose - previous.AdjClose) / previous.AdjClose
 }
 
-func DownloadTickers(saveDir string) error {
-	var wg sync.WaitGroup
-
-	for _, ticker := range basis.TICKERS {
-		wg.Add(1)
-		go downloadTicker(ticker, saveDir, &wg)
+// BarsToRecords converts chronological bars into PriceRecords holding the return since the previous bar,
+// so the first bar only serves as the starting price. The records are keyed by the ticker.
+func BarsToRecords(ticker string, bars []Bar) structs.PriceRecords {
+	if len(bars) < 2 {
+		return structs.PriceRecords{}
 	}
 
-	wg.Wait()
-	return nil
-}
-
-func downloadTicker(ticker, saveDir string, wg *sync.WaitGroup) {
-	fmt.Printf("getting %5s\n", ticker)
-	_ = downloadMutualFundData(ticker, saveDir)
-	wg.Done()
+	records := make(structs.PriceRecords, len(bars)-1)
+	for i := 1; i < len(bars); i++ {
+		records[i-1] = structs.PriceRecord{
+			EntityID: ticker,
+			Period:   bars[i].Date,
+			Value:    calculatePercentChange(&bars[i], &bars[i-1]),
+		}
+	}
+	return records
 }
 
-func downloadMutualFundData(ticker, saveDir string) error {
-	// internal variables
-	tickerUrl := baseUrl + ticker + "/prices"
-	filename := filepath.Join(saveDir, ticker+".json")
-	out, _ := os.Create(filename)
-	defer out.Close()
-
-	req, err := http.NewRequest(http.MethodGet, tickerUrl, nil)
-	if err != nil {
-		return err
+// DownloadTickers fetches the full price history of each ticker through the manager and saves it to saveDir.
+// The report holds the outcome of every ticker; the error is the report's Err, if any ticker failed.
+func DownloadTickers(ctx context.Context, m *Manager, tickers []string, saveDir string) (Report, error) {
+	jobs := make([]Job, len(tickers))
+	for i, ticker := range tickers {
+		jobs[i] = Job{Ticker: ticker, End: time.Now()}
 	}
 
-	// Query Variables
-	today := time.Now().Format("2006-01-02")
-	q := req.URL.Query()
-	q.Add("endDate", today)
-	q.Add("token", basis.ApiToken)
-	q.Add("format", basis.ResponseFormatJson)
-	q.Add("resampleFreq", basis.ResponseFrequencyMonthly)
-	q.Add("columns", basis.ResponseColumns)
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	report := m.Run(ctx, jobs, SaveTo(saveDir))
+	return report, report.Err()
+}
 
-	// save the data to file
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return err
+// FetchTickers fetches the price history of each ticker straight from the provider, without saving it
+func FetchTickers(ctx context.Context, p Provider, tickers []string, start, end time.Time) (*structs.AllPerformers, error) {
+	var allPerf structs.AllPerformers = make(structs.AllPerformers, len(tickers))
+	for _, ticker := range tickers {
+		bars, err := p.History(ctx, ticker, start, end)
+		if err != nil {
+			return nil, err
+		}
+		records := BarsToRecords(ticker, bars)
+		allPerf[ticker] = &records
 	}
 
-	return nil
+	return &allPerf, nil
 }
 
-func GetTickers(lookupDir string) (*structs.AllPerformers, error) {
-	var allPerf structs.AllPerformers = make(structs.AllPerformers, len(basis.TICKERS))
-	for _, ticker := range basis.TICKERS {
+// GetTickers reads the price history of each ticker previously saved to lookupDir by DownloadTickers
+func GetTickers(tickers []string, lookupDir string) (*structs.AllPerformers, error) {
+	var allPerf structs.AllPerformers = make(structs.AllPerformers, len(tickers))
+	for _, ticker := range tickers {
 		data, err := getTicker(ticker, lookupDir)
 		if err != nil {
 			return nil, err
@@ -107,35 +83,25 @@ func getTicker(ticker, lookupDir string) (*structs.PriceRecords, error) {
 }
 
 func readDataIntoPriceRecords(ticker, lookupDir string) (*structs.PriceRecords, error) {
-	filename := filepath.Join(lookupDir, ticker+".json")
-	in, err := os.OpenFile(filename, os.O_RDONLY, 0400) // 0400 becuase we only need to read from the file
-	defer in.Close()
-
-	// read the data into memory to convert it into a PriceRecords object
-	body, err := ioutil.ReadAll(in)
+	bars, err := readBars(filepath.Join(lookupDir, ticker+".json"))
 	if err != nil {
 		return nil, err
 	}
 
-	var rawResp []rawTiingoResponse // to hold the API response call
-	if err = json.Unmarshal(body, &rawResp); err != nil {
+	records := BarsToRecords(ticker, bars)
+	return &records, nil
+}
+
+func readBars(filename string) ([]Bar, error) {
+	in, err := os.OpenFile(filename, os.O_RDONLY, 0400) // 0400 becuase we only need to read from the file
+	if err != nil {
 		return nil, err
 	}
+	defer in.Close()
 
-	records := make(structs.PriceRecords, len(rawResp)-1)
-
-	for i := 1; i < len(rawResp); i++ {
-		prevRecord := rawResp[i-1]
-		thisRecord := rawResp[i]
-		thisTime, err := time.Parse(time.RFC3339, thisRecord.TickerDate)
-		if err != nil {
-			return nil, err
-		}
-		records[i-1] = structs.PriceRecord{
-			TickerDate:  thisTime,
-			PriceReturn: thisRecord.calculatePercentChange(&prevRecord),
-		}
+	var bars []Bar
+	if err = json.NewDecoder(in).Decode(&bars); err != nil {
+		return nil, err
 	}
-
-	return &records, nil
+	return bars, nil
 }
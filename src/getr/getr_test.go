@@ -0,0 +1,157 @@
+package getr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reused testing variables
+var (
+	rawTiingoPrices string = `[
+	{"date": "2021-05-31T00:00:00.000Z", "close": 10.0, "adjClose": 100.0},
+	{"date": "2021-06-30T00:00:00.000Z", "close": 11.0, "adjClose": 110.0},
+	{"date": "2021-07-30T00:00:00.000Z", "close": 9.9, "adjClose": 99.0}
+]`
+	testToken string = "test-token"
+)
+
+// newTiingoStandIn serves rawTiingoPrices for every ticker, and records the queries it was sent
+func newTiingoStandIn(t *testing.T, queries chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if queries != nil {
+			queries <- r.URL.Path + "?" + r.URL.RawQuery
+		}
+		if r.URL.Query().Get("token") != testToken {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(r.URL.Path, "MISSING") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, rawTiingoPrices)
+	}))
+}
+
+func newTestTiingo(server *httptest.Server) *Tiingo {
+	p := NewTiingo(testToken)
+	p.BaseUrl = server.URL + "/tiingo/daily/"
+	p.Client = server.Client()
+	return p
+}
+
+func TestTiingoHistory(t *testing.T) {
+	queries := make(chan string, 1)
+	server := newTiingoStandIn(t, queries)
+	defer server.Close()
+
+	start := time.Date(2021, time.May, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	bars, err := newTestTiingo(server).History(context.Background(), "VFIAX", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := <-queries
+	for _, want := range []string{"/tiingo/daily/VFIAX/prices", "startDate=2021-05-01", "endDate=2021-08-01", "resampleFreq=monthly", "format=json"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Request to Tiingo should have contained %q, but was %s", want, query)
+		}
+	}
+
+	if len(bars) != 3 {
+		t.Fatalf("History should have returned 3 bars, but got %d", len(bars))
+	}
+	wantDate := time.Date(2021, time.June, 30, 0, 0, 0, 0, time.UTC)
+	if !bars[1].Date.Equal(wantDate) || bars[1].Close != 11 || bars[1].AdjClose != 110 {
+		t.Errorf("Second bar should have been 2021-06-30 close 11 adjClose 110, but got %+v", bars[1])
+	}
+}
+
+func TestTiingoHistoryReturnsStatusError(t *testing.T) {
+	server := newTiingoStandIn(t, nil)
+	defer server.Close()
+
+	_, err := newTestTiingo(server).History(context.Background(), "MISSING", time.Time{}, time.Time{})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("History should have returned a StatusError, but got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound || statusErr.Ticker != "MISSING" {
+		t.Errorf("StatusError should have been a 404 for MISSING, but got %+v", statusErr)
+	}
+}
+
+func TestBarsToRecords(t *testing.T) {
+	bars := []Bar{
+		{Date: time.Date(2021, time.May, 31, 0, 0, 0, 0, time.UTC), AdjClose: 100},
+		{Date: time.Date(2021, time.June, 30, 0, 0, 0, 0, time.UTC), AdjClose: 110},
+		{Date: time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC), AdjClose: 99},
+	}
+	want := []float64{0.1, -0.1}
+
+	records := BarsToRecords("AAAAX", bars)
+	if len(records) != len(want) {
+		t.Fatalf("BarsToRecords should have returned %d records, but got %d", len(want), len(records))
+	}
+	for i, w := range want {
+		if got := records[i].Value; got < w-1e-9 || got > w+1e-9 {
+			t.Errorf("For record %d, wanted a return of %f, but got %f", i, w, got)
+		}
+		if records[i].EntityID != "AAAAX" || records[i].ProductID != "" {
+			t.Errorf("For record %d, wanted the ticker as EntityID only, but got %q and %q", i, records[i].EntityID, records[i].ProductID)
+		}
+		if !records[i].Period.Equal(bars[i+1].Date) {
+			t.Errorf("For record %d, wanted date %s, but got %s", i, bars[i+1].Date, records[i].Period)
+		}
+	}
+
+	if len(BarsToRecords("AAAAX", bars[:1])) != 0 {
+		t.Error("A single bar has no returns, so BarsToRecords should have returned no records")
+	}
+}
+
+func TestDownloadThenGetTickers(t *testing.T) {
+	server := newTiingoStandIn(t, nil)
+	defer server.Close()
+
+	saveDir := t.TempDir()
+	tickers := []string{"AAAAX", "BBBBX"}
+	if _, err := DownloadTickers(context.Background(), NewManager(newTestTiingo(server)), tickers, saveDir); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := GetTickers(tickers, saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := FetchTickers(context.Background(), newTestTiingo(server), tickers, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ticker := range tickers {
+		s, f := *(*saved)[ticker], *(*fetched)[ticker]
+		if len(s) != 2 || len(s) != len(f) {
+			t.Fatalf("For %s, saved and fetched histories should both have 2 records, but got %d and %d", ticker, len(s), len(f))
+		}
+		for i := range s {
+			if s[i] != f[i] {
+				t.Errorf("For %s record %d, saved %+v but fetched %+v", ticker, i, s[i], f[i])
+			}
+		}
+	}
+}
+
+func TestGetTickersErrorsOnMissingFile(t *testing.T) {
+	_, err := GetTickers([]string{"NOPE"}, t.TempDir())
+	if err == nil {
+		t.Error("GetTickers should have errored for a ticker which was never downloaded, but didn't")
+	}
+}
@@ -0,0 +1,262 @@
+package getr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is a single ticker's date range to download. Zero dates leave that side of the range open.
+type Job struct {
+	Ticker string
+	Start  time.Time
+	End    time.Time
+}
+
+// Sink receives the bars of each successful download, e.g. to save them to disk
+type Sink func(ticker string, bars []Bar) error
+
+// Result records how the download of a single ticker went
+type Result struct {
+	Ticker   string
+	Bars     int // the number of bars received
+	Attempts int // the number of requests made, including retries
+	Err      error
+}
+
+// Report maps each requested ticker to the Result of its download
+type Report map[string]*Result
+
+// Failed returns the tickers which could not be downloaded, in alphabetical order
+func (r Report) Failed() []string {
+	var failed []string
+	for ticker, result := range r {
+		if result.Err != nil {
+			failed = append(failed, ticker)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// Err returns a *DownloadError describing every failed ticker, or nil if all succeeded
+func (r Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	errs := make(map[string]error, len(failed))
+	for _, ticker := range failed {
+		errs[ticker] = r[ticker].Err
+	}
+	return &DownloadError{Failed: errs, Total: len(r)}
+}
+
+// DownloadError aggregates the errors of every ticker which failed to download
+type DownloadError struct {
+	Failed map[string]error
+	Total  int
+}
+
+func (e *DownloadError) Error() string {
+	tickers := make([]string, 0, len(e.Failed))
+	for ticker := range e.Failed {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	reasons := make([]string, len(tickers))
+	for i, ticker := range tickers {
+		reasons[i] = fmt.Sprintf("%s (%s)", ticker, e.Failed[ticker])
+	}
+	return fmt.Sprintf("%d of %d tickers failed to download: %s", len(tickers), e.Total, strings.Join(reasons, ", "))
+}
+
+// IsRetryable reports whether a failed request is worth trying again: rate limiting (429),
+// server side errors (5xx) and network timeouts are, anything else is not
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// Manager downloads many tickers from a Provider with a bounded pool of workers,
+// a shared rate limit, and exponential backoff on retryable failures
+type Manager struct {
+	Provider   Provider
+	Workers    int                  // the number of concurrent downloads
+	Interval   time.Duration        // the minimum time between any two requests; zero for no limit
+	MaxRetries int                  // the number of retries after the first attempt
+	Backoff    time.Duration        // the wait before the first retry, doubled for every retry after
+	MaxBackoff time.Duration        // the longest wait between retries
+	Retryable  func(err error) bool // decides which errors are retried; IsRetryable when nil
+}
+
+// NewManager returns a Manager with defaults suited to Tiingo's free tier
+func NewManager(p Provider) *Manager {
+	return &Manager{
+		Provider:   p,
+		Workers:    4,
+		Interval:   250 * time.Millisecond,
+		MaxRetries: 3,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (m *Manager) backoff(retry int, err error) time.Duration {
+	wait := m.Backoff << uint(retry)
+	if wait < m.Backoff || (m.MaxBackoff > 0 && wait > m.MaxBackoff) {
+		// the shift overflowed or went past the cap
+		wait = m.MaxBackoff
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+		wait = statusErr.RetryAfter
+	}
+	return wait
+}
+
+// Run downloads every job and passes the bars to sink, returning a Result for every ticker.
+// It only returns once every job has finished or ctx is done.
+func (m *Manager) Run(ctx context.Context, jobs []Job, sink Sink) Report {
+	workers := m.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	retryable := m.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	// every worker takes a tick before each request, so the rate limit is shared
+	var limiter <-chan time.Time
+	if m.Interval > 0 {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = make(Report, len(jobs))
+		queue  = make(chan Job)
+	)
+
+	download := func(job Job) *Result {
+		result := &Result{Ticker: job.Ticker}
+		for {
+			if limiter != nil {
+				select {
+				case <-ctx.Done():
+					result.Err = ctx.Err()
+					return result
+				case <-limiter:
+				}
+			}
+
+			result.Attempts++
+			bars, err := m.Provider.History(ctx, job.Ticker, job.Start, job.End)
+			if err == nil {
+				result.Bars = len(bars)
+				if sink != nil {
+					result.Err = sink(job.Ticker, bars)
+				}
+				return result
+			}
+
+			retry := result.Attempts - 1
+			if retry >= m.MaxRetries || !retryable(err) {
+				result.Err = err
+				return result
+			}
+			if sleepErr := sleepContext(ctx, m.backoff(retry, err)); sleepErr != nil {
+				result.Err = err
+				return result
+			}
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				result := download(job)
+				mu.Lock()
+				report[job.Ticker] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	return report
+}
+
+// writeJSONAtomic saves v to filename by writing to a temporary file in the same
+// directory and renaming it into place, so a failed download never leaves a partial file
+func writeJSONAtomic(filename string, v interface{}) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// a no-op once the rename has succeeded
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// SaveTo returns a Sink which saves each ticker's bars to <saveDir>/<ticker>.json
+func SaveTo(saveDir string) Sink {
+	return func(ticker string, bars []Bar) error {
+		return writeJSONAtomic(filepath.Join(saveDir, ticker+".json"), bars)
+	}
+}
@@ -0,0 +1,230 @@
+package getr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedProvider fails each ticker with the scripted errors, in order, before succeeding
+type scriptedProvider struct {
+	mu       sync.Mutex
+	failures map[string][]error
+	calls    map[string]int
+	inFlight int
+	maxSeen  int
+	delay    time.Duration
+}
+
+func newScriptedProvider(failures map[string][]error) *scriptedProvider {
+	return &scriptedProvider{failures: failures, calls: make(map[string]int)}
+}
+
+func (p *scriptedProvider) History(ctx context.Context, ticker string, start, end time.Time) ([]Bar, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxSeen {
+		p.maxSeen = p.inFlight
+	}
+	call := p.calls[ticker]
+	p.calls[ticker]++
+	p.mu.Unlock()
+
+	time.Sleep(p.delay)
+
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+
+	if call < len(p.failures[ticker]) {
+		return nil, p.failures[ticker][call]
+	}
+	return []Bar{{Date: time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC), Close: 1, AdjClose: 1}}, nil
+}
+
+func tooManyRequests(ticker string) error {
+	return &StatusError{Ticker: ticker, StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+}
+
+func newTestManager(p Provider) *Manager {
+	return &Manager{
+		Provider:   p,
+		Workers:    2,
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+	}
+}
+
+func TestManagerRetriesRetryableErrors(t *testing.T) {
+	p := newScriptedProvider(map[string][]error{
+		"FLAKY": {tooManyRequests("FLAKY"), tooManyRequests("FLAKY")},
+		"GONE":  {&StatusError{Ticker: "GONE", StatusCode: http.StatusNotFound, Status: "404 Not Found"}},
+		"DOWN":  {tooManyRequests("DOWN"), tooManyRequests("DOWN"), tooManyRequests("DOWN")},
+		"FINE":  nil,
+	})
+
+	report := newTestManager(p).Run(context.Background(), []Job{{Ticker: "FLAKY"}, {Ticker: "GONE"}, {Ticker: "DOWN"}, {Ticker: "FINE"}}, nil)
+
+	type want struct {
+		Attempts int
+		Failed   bool
+	}
+	wants := map[string]want{
+		"FLAKY": {Attempts: 3, Failed: false},
+		"GONE":  {Attempts: 1, Failed: true},
+		"DOWN":  {Attempts: 3, Failed: true},
+		"FINE":  {Attempts: 1, Failed: false},
+	}
+	for ticker, w := range wants {
+		got := report[ticker]
+		if got == nil {
+			t.Errorf("Report is missing a result for %s", ticker)
+			continue
+		}
+		if got.Attempts != w.Attempts {
+			t.Errorf("For %s, wanted %d attempts, but got %d", ticker, w.Attempts, got.Attempts)
+		}
+		if (got.Err != nil) != w.Failed {
+			t.Errorf("For %s, wanted failed to be %t, but got error %v", ticker, w.Failed, got.Err)
+		}
+	}
+
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0] != "DOWN" || failed[1] != "GONE" {
+		t.Errorf("Failed should have returned [DOWN GONE], but got %v", failed)
+	}
+
+	var downloadErr *DownloadError
+	if !errors.As(report.Err(), &downloadErr) {
+		t.Fatalf("Err should have returned a DownloadError, but got %v", report.Err())
+	}
+	if downloadErr.Total != 4 || len(downloadErr.Failed) != 2 || !strings.Contains(downloadErr.Error(), "2 of 4") {
+		t.Errorf("DownloadError should have described 2 of 4 tickers failing, but got %s", downloadErr)
+	}
+}
+
+func TestManagerBoundsConcurrency(t *testing.T) {
+	p := newScriptedProvider(nil)
+	p.delay = 5 * time.Millisecond
+
+	var jobs []Job
+	for _, ticker := range []string{"A", "B", "C", "D", "E", "F", "G", "H"} {
+		jobs = append(jobs, Job{Ticker: ticker})
+	}
+	m := newTestManager(p)
+	m.Workers = 3
+	report := m.Run(context.Background(), jobs, nil)
+
+	if report.Err() != nil {
+		t.Fatal(report.Err())
+	}
+	if len(report) != len(jobs) {
+		t.Errorf("Report should have a result for each of %d jobs, but has %d", len(jobs), len(report))
+	}
+	if p.maxSeen > 3 {
+		t.Errorf("At most 3 requests should have been in flight, but saw %d", p.maxSeen)
+	}
+}
+
+func TestManagerRateLimits(t *testing.T) {
+	p := newScriptedProvider(nil)
+	m := newTestManager(p)
+	m.Workers = 4
+	m.Interval = 10 * time.Millisecond
+
+	started := time.Now()
+	m.Run(context.Background(), []Job{{Ticker: "A"}, {Ticker: "B"}, {Ticker: "C"}, {Ticker: "D"}}, nil)
+
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("4 requests 10ms apart should have taken at least 40ms, but took %s", elapsed)
+	}
+}
+
+func TestManagerStopsOnCancelledContext(t *testing.T) {
+	p := newScriptedProvider(map[string][]error{"SLOW": {tooManyRequests("SLOW")}})
+	m := newTestManager(p)
+	m.Backoff = time.Hour
+	m.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report := m.Run(ctx, []Job{{Ticker: "SLOW"}}, nil)
+
+	if report["SLOW"].Err == nil || report["SLOW"].Attempts != 1 {
+		t.Errorf("A cancelled context should stop retries after the first attempt, but got %+v", report["SLOW"])
+	}
+}
+
+func TestBackoffHonoursRetryAfterAndCap(t *testing.T) {
+	m := &Manager{Backoff: time.Second, MaxBackoff: 4 * time.Second}
+
+	type testCase struct {
+		Retry int
+		Err   error
+		Want  time.Duration
+	}
+	var testCases []testCase = []testCase{
+		{Retry: 0, Err: errors.New("x"), Want: time.Second},
+		{Retry: 1, Err: errors.New("x"), Want: 2 * time.Second},
+		{Retry: 5, Err: errors.New("x"), Want: 4 * time.Second},
+		{Retry: 0, Err: &StatusError{StatusCode: 429, RetryAfter: 10 * time.Second}, Want: 10 * time.Second},
+	}
+	for i, tc := range testCases {
+		if got := m.backoff(tc.Retry, tc.Err); got != tc.Want {
+			t.Errorf("For test case %d, wanted a backoff of %s, but got %s", i, tc.Want, got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	type testCase struct {
+		Err  error
+		Want bool
+	}
+	var testCases []testCase = []testCase{
+		{Err: &StatusError{StatusCode: http.StatusTooManyRequests}, Want: true},
+		{Err: &StatusError{StatusCode: http.StatusBadGateway}, Want: true},
+		{Err: &StatusError{StatusCode: http.StatusNotFound}, Want: false},
+		{Err: errors.New("invalid character in JSON"), Want: false},
+	}
+	for i, tc := range testCases {
+		if got := IsRetryable(tc.Err); got != tc.Want {
+			t.Errorf("For test case %d (%v), wanted %t, but got %t", i, tc.Err, tc.Want, got)
+		}
+	}
+}
+
+func TestSaveToWritesAtomically(t *testing.T) {
+	saveDir := t.TempDir()
+	p := newScriptedProvider(map[string][]error{"BAD": {errors.New("boom")}})
+
+	report := newTestManager(p).Run(context.Background(), []Job{{Ticker: "GOOD"}, {Ticker: "BAD"}}, SaveTo(saveDir))
+	if report["GOOD"].Err != nil || report["GOOD"].Bars != 1 {
+		t.Errorf("GOOD should have saved 1 bar, but got %+v", report["GOOD"])
+	}
+
+	entries, err := os.ReadDir(saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "GOOD.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Only GOOD.json should have been written, without temporary files, but found %v", names)
+	}
+
+	// a sink error is reported against the ticker
+	report = newTestManager(p).Run(context.Background(), []Job{{Ticker: "GOOD"}}, SaveTo(filepath.Join(saveDir, "missing")))
+	if report["GOOD"].Err == nil {
+		t.Error("Saving to a directory which does not exist should have been reported as an error, but wasn't")
+	}
+}
@@ -0,0 +1,33 @@
+package getr
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Bar is a single normalized price observation, whichever provider it came from.
+// The json tags match Tiingo's response, so files saved before providers existed still load.
+type Bar struct {
+	Date     time.Time `json:"date"`
+	Close    float64   `json:"close"`
+	AdjClose float64   `json:"adjClose"`
+}
+
+// Provider fetches the price history of a ticker from a market-data source.
+// A zero start or end leaves that side of the date range open.
+type Provider interface {
+	History(ctx context.Context, ticker string, start, end time.Time) ([]Bar, error)
+}
+
+// StatusError is returned by a Provider when its source answers with anything other than 200 OK
+type StatusError struct {
+	Ticker     string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // how long the source asked us to wait before trying again, if it said
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request for %s failed with status %s", e.Ticker, e.Status)
+}
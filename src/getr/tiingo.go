@@ -0,0 +1,106 @@
+package getr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const TiingoBaseUrl = "https://api.tiingo.com/tiingo/daily/"
+const DefaultTimeout = time.Second * 8
+
+const tiingoDateLayout = "2006-01-02"
+
+type rawTiingoResponse struct {
+	TickerDate time.Time `json:"date"`
+	Close      float64   `json:"close"`
+	AdjClose   float64   `json:"adjClose"`
+}
+
+// Tiingo is the Provider for Tiingo's end-of-day prices API
+type Tiingo struct {
+	BaseUrl      string
+	Token        string
+	ResampleFreq string // e.g. "monthly"; empty for daily prices
+	Columns      string // the columns requested, which must include date, close and adjClose
+	Client       *http.Client
+}
+
+// NewTiingo returns a Tiingo provider for monthly prices from the live API
+func NewTiingo(token string) *Tiingo {
+	return &Tiingo{
+		BaseUrl:      TiingoBaseUrl,
+		Token:        token,
+		ResampleFreq: "monthly",
+		Columns:      "date,close,adjClose",
+		Client:       &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// History implements Provider
+func (t *Tiingo) History(ctx context.Context, ticker string, start, end time.Time) ([]Bar, error) {
+	tickerUrl := t.BaseUrl + ticker + "/prices"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tickerUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query Variables
+	q := req.URL.Query()
+	if !start.IsZero() {
+		q.Add("startDate", start.Format(tiingoDateLayout))
+	}
+	if !end.IsZero() {
+		q.Add("endDate", end.Format(tiingoDateLayout))
+	}
+	q.Add("token", t.Token)
+	q.Add("format", "json")
+	if t.ResampleFreq != "" {
+		q.Add("resampleFreq", t.ResampleFreq)
+	}
+	if t.Columns != "" {
+		q.Add("columns", t.Columns)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{
+			Ticker:     ticker,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var rawResp []rawTiingoResponse // to hold the API response call
+	if err := json.NewDecoder(resp.Body).Decode(&rawResp); err != nil {
+		return nil, err
+	}
+
+	bars := make([]Bar, len(rawResp))
+	for i, r := range rawResp {
+		bars[i] = Bar{Date: r.TickerDate, Close: r.Close, AdjClose: r.AdjClose}
+	}
+	return bars, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds. HTTP dates are ignored.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
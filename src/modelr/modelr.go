@@ -0,0 +1,154 @@
+package modelr
+
+import (
+	"errors"
+	"hash/fnv"
+
+	"github.com/Viking2012/goraynor/src/quantilr"
+	"github.com/Viking2012/goraynor/src/structs"
+	"github.com/Viking2012/goraynor/src/transitions"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+var (
+	NoPurchasesError error = errors.New("the product has no purchases to model")
+	NoWeightError    error = errors.New("none of the product's purchases were assigned a decile")
+)
+
+// Source decides where a product's decile weights come from
+type Source int
+
+const (
+	// Occupancy weights each decile by the share of the product's purchases which fall in it
+	Occupancy Source = iota
+	// Stationary weights each decile by the long-run share of purchases the product's customer
+	// transitions settle into, pooled over every customer
+	Stationary
+)
+
+// Config holds everything which decides how a product's model is built
+type Config struct {
+	Source    Source
+	N         int                        // the number of buckets, 10 for deciles
+	Estimator quantilr.Estimator         // how the product's decile boundaries are estimated
+	Weight    structs.WeightFunc         // what each purchase weighs in the boundaries; nil counts each once
+	Policy    transitions.EmptyRowPolicy // for deciles no customer moved out of, under Stationary
+	Seed      uint64                     // the base seed; each product's seed is derived from it
+}
+
+var DefaultConfig Config = Config{
+	Source: Stationary,
+	N:      10,
+	Policy: transitions.UniformRow,
+	Seed:   123456,
+}
+
+// ProductSeed derives a product's seed from the base seed, so that every product samples
+// independently of the others, and the same regardless of the order products are fitted in
+func ProductSeed(base uint64, productID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(productID))
+	return base ^ h.Sum64()
+}
+
+// Fit computes deciles over every purchase price of the product, weighted by cfg.Weight, assigns
+// each purchase its decile (clamping those which weigh nothing into the fitted range), recounts each customer's DecileCounts and stores the product's
+// Deciles and a ready-to-sample DecileModel, along with the Seed it was built with
+func Fit(p *structs.ProductContainer, cfg Config) error {
+	var purchases structs.PriceRecords
+	for _, c := range p.CustomerRecords {
+		purchases = append(purchases, c.PriceRecords...)
+	}
+	if len(purchases) == 0 {
+		return NoPurchasesError
+	}
+
+	counts, err := purchases.Count(cfg.Weight)
+	if err != nil {
+		return err
+	}
+	d, err := quantilr.Fit(counts, quantilr.Config{N: cfg.N, Estimator: cfg.Estimator, Deduplicate: true})
+	if err != nil {
+		return err
+	}
+	var records []*structs.PriceRecord
+	for i := range p.CustomerRecords {
+		for j := range p.CustomerRecords[i].PriceRecords {
+			records = append(records, &p.CustomerRecords[i].PriceRecords[j])
+		}
+	}
+	if err := structs.SetWeightedBuckets(records, d, cfg.Weight); err != nil {
+		return err
+	}
+	for i := range p.CustomerRecords {
+		p.CustomerRecords[i].CountDeciles()
+	}
+
+	weights, err := Weights(p, cfg)
+	if err != nil {
+		return err
+	}
+
+	p.Deciles = d
+	p.Seed = ProductSeed(cfg.Seed, p.ProductID)
+	p.DecileModel = distuv.NewCategorical(weights, rand.NewSource(p.Seed))
+	return nil
+}
+
+// FitAll fits every product, stopping at the first which cannot be fitted
+func FitAll(products []structs.ProductContainer, cfg Config) error {
+	for i := range products {
+		if err := Fit(&products[i], cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transitions counts the moves between the deciles of consecutive purchases of each customer,
+// pooled over all of the product's customers. Deciles must already be assigned, e.g. by Fit.
+func Transitions(p *structs.ProductContainer, n int) (transitions.Matrix, error) {
+	m, err := transitions.NewMatrix(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range p.CustomerRecords {
+		if err := m.AddRecords(structs.PriceRecords(c.PriceRecords)); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Weights returns the weight of each decile (index 0 for decile 1) under cfg.Source.
+// Deciles must already be assigned, e.g. by Fit.
+func Weights(p *structs.ProductContainer, cfg Config) ([]float64, error) {
+	if cfg.Source == Stationary {
+		counts, err := Transitions(p, cfg.N)
+		if err != nil {
+			return nil, err
+		}
+		model, err := counts.Normalize(cfg.Policy)
+		if err != nil {
+			return nil, err
+		}
+		return model.Stationary()
+	}
+
+	weights := make([]float64, cfg.N)
+	var total float64
+	for _, c := range p.CustomerRecords {
+		for _, pair := range c.DecileCounts {
+			if pair.Decile < 1 || int(pair.Decile) > cfg.N {
+				return nil, transitions.DecileOutOfRange
+			}
+			weights[pair.Decile-1] += pair.Weight
+			total += pair.Weight
+		}
+	}
+	if total == 0 {
+		return nil, NoWeightError
+	}
+	return weights, nil
+}
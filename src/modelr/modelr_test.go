@@ -0,0 +1,188 @@
+package modelr
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func day(d int) time.Time {
+	return time.Date(2017, time.March, d, 0, 0, 0, 0, time.UTC)
+}
+
+// testProduct has four customers of five purchases each, with the prices 1 to 20 dealt out in turn
+func testProduct() *structs.ProductContainer {
+	p := &structs.ProductContainer{ProductID: "bed_bath_table:8"}
+	for c, id := range []string{"15df0", "f4c13", "0dc4b", "d98e2"} {
+		customer := structs.CustomerContainer{CustomerID: id}
+		for i := 0; i < 5; i++ {
+			customer.PriceRecords = append(customer.PriceRecords, structs.PriceRecord{
+				ProductID:  p.ProductID,
+				CustomerID: id,
+				Period:     day(i + 1),
+				Value:      float64(c + 4*i + 1),
+			})
+		}
+		customer.CountDeciles()
+		p.CustomerRecords = append(p.CustomerRecords, customer)
+	}
+	return p
+}
+
+func TestFitAssignsDeciles(t *testing.T) {
+	p := testProduct()
+	if err := Fit(p, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Deciles.N() != 10 {
+		t.Errorf("The product's deciles should have N of 10, but got %d", p.Deciles.N())
+	}
+	for _, c := range p.CustomerRecords {
+		var counted float64
+		for _, pair := range c.DecileCounts {
+			counted += pair.Weight
+		}
+		if counted != float64(c.LifeSpan) {
+			t.Errorf("For customer %s, every purchase should have been counted in a decile, but %3.1f of %d were", c.CustomerID, counted, c.LifeSpan)
+		}
+		for _, pr := range c.PriceRecords {
+			// prices 1 to 20 give two prices per decile
+			if want := int8((pr.Value + 1) / 2); pr.Bucket != want {
+				t.Errorf("For price %3.1f, wanted decile %d, but got %d", pr.Value, want, pr.Bucket)
+			}
+		}
+	}
+
+	if p.Seed != ProductSeed(DefaultConfig.Seed, p.ProductID) {
+		t.Errorf("The product's seed should have been derived from the base seed, but got %d", p.Seed)
+	}
+	var total float64
+	for d := 0; d < 10; d++ {
+		total += p.DecileModel.Prob(float64(d))
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("The decile model's probabilities should sum to 1, but sum to %f", total)
+	}
+}
+
+func TestFitWeighted(t *testing.T) {
+	p := testProduct()
+	cfg := DefaultConfig
+	cfg.Weight = structs.ByValue
+	if err := Fit(p, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// weighted by price, the prices 1 to 6 carry the first tenth of the weight and 20 the last
+	want := map[float64]int8{1: 1, 6: 1, 7: 2, 19: 9, 20: 10}
+	for _, c := range p.CustomerRecords {
+		for _, pr := range c.PriceRecords {
+			if decile, ok := want[pr.Value]; ok && pr.Bucket != decile {
+				t.Errorf("For price %3.1f, wanted decile %d, but got %d", pr.Value, decile, pr.Bucket)
+			}
+		}
+	}
+}
+
+func TestFitClampsPurchasesWhichWeighNothing(t *testing.T) {
+	p := testProduct()
+	// the priciest and cheapest purchases weigh nothing, so are left out of the boundaries
+	weights := make(map[int64]float64)
+	for i := range p.CustomerRecords {
+		for j := range p.CustomerRecords[i].PriceRecords {
+			pr := &p.CustomerRecords[i].PriceRecords[j]
+			pr.Uuid = int64(pr.Value)
+			if pr.Value != 1 && pr.Value != 20 {
+				weights[pr.Uuid] = 1
+			}
+		}
+	}
+
+	cfg := DefaultConfig
+	cfg.Weight = structs.WeightsByUuid(weights)
+	if err := Fit(p, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range p.CustomerRecords {
+		for _, pr := range c.PriceRecords {
+			if pr.Value == 1 && pr.Bucket != 1 || pr.Value == 20 && pr.Bucket != 10 {
+				t.Errorf("For the weightless price %3.1f, wanted it clamped into the range, but got decile %d", pr.Value, pr.Bucket)
+			}
+		}
+	}
+}
+
+func TestFitOccupancy(t *testing.T) {
+	p := testProduct()
+	cfg := DefaultConfig
+	cfg.Source = Occupancy
+	if err := Fit(p, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for d := 0; d < 10; d++ {
+		if got := p.DecileModel.Prob(float64(d)); math.Abs(got-0.1) > 1e-9 {
+			t.Errorf("Every decile holds two of the twenty purchases, so decile %d should have probability 0.1, but got %f", d+1, got)
+		}
+	}
+}
+
+func TestFitStationaryMatchesTransitions(t *testing.T) {
+	p := testProduct()
+	if err := Fit(p, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := Transitions(p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every customer buys at steadily rising prices, moving up two deciles at a time
+	if counts.Get(1, 3) != 2 || counts.Get(2, 4) != 2 {
+		t.Errorf("Customers should each have moved up two deciles per purchase, but got %v", counts)
+	}
+
+	model, err := counts.Normalize(DefaultConfig.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := model.Stationary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d, w := range want {
+		if got := p.DecileModel.Prob(float64(d)); math.Abs(got-w) > 1e-9 {
+			t.Errorf("For decile %d, wanted the stationary probability %f, but got %f", d+1, w, got)
+		}
+	}
+}
+
+func TestFitIsReproducible(t *testing.T) {
+	a, b := testProduct(), testProduct()
+	if err := Fit(a, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fit(b, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if x, y := a.DecileModel.Rand(), b.DecileModel.Rand(); x != y {
+			t.Fatalf("Models fitted with the same seed should sample alike, but draw %d gave %f and %f", i, x, y)
+		}
+	}
+
+	if ProductSeed(1, "bed_bath_table:8") == ProductSeed(1, "bed_bath_table:9") {
+		t.Error("Different products should be given different seeds")
+	}
+}
+
+func TestFitErrorsWithoutPurchases(t *testing.T) {
+	p := &structs.ProductContainer{ProductID: "empty"}
+	if err := Fit(p, DefaultConfig); err != NoPurchasesError {
+		t.Errorf("Fit should have returned NoPurchasesError, but got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package organizr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+var NotSortedError error = errors.New("records must be sorted by product, customer and date first")
+
+// GroupBy walks records which have been sorted with OrderedBy(ByProduct, ByCustomer, ByDate, ...)
+// and builds one ProductContainer per product, holding one CustomerContainer per customer with
+// their purchases in date order. Each customer's LifeSpan and DecileCounts are counted from their
+// records' buckets, so set those first for DecileCounts to mean anything.
+// The records are copied, so the containers do not share memory with the given slice.
+func GroupBy(records []structs.PriceRecord) ([]structs.ProductContainer, error) {
+	var products []structs.ProductContainer
+	seenProducts := make(map[string]bool)
+	var seenCustomers map[string]bool
+
+	for i := range records {
+		pr := records[i]
+
+		newProduct := len(products) == 0 || products[len(products)-1].ProductID != pr.ProductID
+		if newProduct {
+			if seenProducts[pr.ProductID] {
+				return nil, fmt.Errorf("%w: product %s appears again at record %d", NotSortedError, pr.ProductID, i)
+			}
+			seenProducts[pr.ProductID] = true
+			seenCustomers = make(map[string]bool)
+			products = append(products, structs.ProductContainer{ProductID: pr.ProductID})
+		}
+		product := &products[len(products)-1]
+
+		customers := product.CustomerRecords
+		if newProduct || customers[len(customers)-1].CustomerID != pr.CustomerID {
+			if seenCustomers[pr.CustomerID] {
+				return nil, fmt.Errorf("%w: customer %s of product %s appears again at record %d", NotSortedError, pr.CustomerID, pr.ProductID, i)
+			}
+			seenCustomers[pr.CustomerID] = true
+			product.CustomerRecords = append(product.CustomerRecords, structs.CustomerContainer{CustomerID: pr.CustomerID})
+		}
+		customer := &product.CustomerRecords[len(product.CustomerRecords)-1]
+
+		if n := len(customer.PriceRecords); n > 0 && pr.Period.Before(customer.PriceRecords[n-1].Period) {
+			return nil, fmt.Errorf("%w: record %d is dated before the purchase preceding it", NotSortedError, i)
+		}
+		customer.PriceRecords = append(customer.PriceRecords, pr)
+	}
+
+	for i := range products {
+		for j := range products[i].CustomerRecords {
+			products[i].CustomerRecords[j].CountDeciles()
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,103 @@
+package organizr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func sortedRawRecords() []structs.PriceRecord {
+	s := make([]structs.PriceRecord, len(RawRecords))
+	copy(s, RawRecords)
+	OrderedBy(ByProduct, ByCustomer, ByDate, ByDocumentNumber, ByDocumentLineNumber).Sort(s)
+	return s
+}
+
+func TestGroupBy(t *testing.T) {
+	s := sortedRawRecords()
+	// give one customer some buckets to count
+	for i := range s {
+		if s[i].ProductID == "bed_bath_table:8" && s[i].CustomerID == "f4c13" {
+			s[i].Bucket = int8(i%2 + 9)
+		}
+	}
+
+	products, err := GroupBy(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || products[0].ProductID != "bed_bath_table:8" || products[1].ProductID != "bed_bath_table:9" {
+		t.Fatalf("GroupBy should have returned both products in order, but got %d products", len(products))
+	}
+
+	type testCase struct {
+		Product  int
+		Customer string
+		LifeSpan int
+	}
+	var testCases []testCase = []testCase{
+		{0, "f4c13", 3},
+		{0, "15df0", 1},
+		{1, "d98e2", 2},
+		{1, "5af63", 2},
+	}
+	for _, tc := range testCases {
+		var found bool
+		for _, c := range products[tc.Product].CustomerRecords {
+			if c.CustomerID != tc.Customer {
+				continue
+			}
+			found = true
+			if c.LifeSpan != tc.LifeSpan || len(c.PriceRecords) != tc.LifeSpan {
+				t.Errorf("For customer %s, wanted a lifespan of %d, but got %d with %d records", tc.Customer, tc.LifeSpan, c.LifeSpan, len(c.PriceRecords))
+			}
+		}
+		if !found {
+			t.Errorf("Customer %s was not found under product %d", tc.Customer, tc.Product)
+		}
+	}
+
+	var customers int
+	for _, p := range products {
+		customers += len(p.CustomerRecords)
+	}
+	if customers != 16 {
+		t.Errorf("GroupBy should have found 16 product/customer pairs, but found %d", customers)
+	}
+
+	for _, c := range products[0].CustomerRecords {
+		if c.CustomerID != "f4c13" {
+			if len(c.DecileCounts) != 0 {
+				t.Errorf("Customer %s has no buckets set, but got decile counts %v", c.CustomerID, c.DecileCounts)
+			}
+			continue
+		}
+		var total float64
+		for _, pair := range c.DecileCounts {
+			if pair.Decile != 9 && pair.Decile != 10 {
+				t.Errorf("Customer f4c13 was only given deciles 9 and 10, but got %v", c.DecileCounts)
+			}
+			total += pair.Weight
+		}
+		if total != 3 {
+			t.Errorf("Customer f4c13's decile counts should total 3, but got %3.1f", total)
+		}
+	}
+}
+
+func TestGroupByErrorsOnUnsortedRecords(t *testing.T) {
+	if _, err := GroupBy(RawRecords); !errors.Is(err, NotSortedError) {
+		t.Errorf("GroupBy should have returned NotSortedError for unsorted records, but got %v", err)
+	}
+
+	split := []structs.PriceRecord{RawRecords[1], RawRecords[0], RawRecords[6]}
+	if _, err := GroupBy(split); !errors.Is(err, NotSortedError) {
+		t.Errorf("GroupBy should have returned NotSortedError for a customer split in two, but got %v", err)
+	}
+
+	backwards := []structs.PriceRecord{RawRecords[15], RawRecords[6]}
+	if _, err := GroupBy(backwards); !errors.Is(err, NotSortedError) {
+		t.Errorf("GroupBy should have returned NotSortedError for purchases out of date order, but got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package organizr
+
+import (
+	"sort"
+
+	"github.com/Viking2012/goraynor/src/countr"
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+// keyFunc is the type of a function which picks the group a PriceRecord belongs to
+type keyFunc func(p *structs.PriceRecord) string
+
+func entityKey(p *structs.PriceRecord) string {
+	return p.EntityID
+}
+
+func productKey(p *structs.PriceRecord) string {
+	return p.ProductID
+}
+
+func customerKey(p *structs.PriceRecord) string {
+	return p.CustomerID
+}
+
+var EntityKey keyFunc = entityKey
+var ProductKey keyFunc = productKey
+var CustomerKey keyFunc = customerKey
+
+// groupTally counts prices per group as records stream past, so each group's deciles can be
+// calculated without holding on to the records themselves
+type groupTally struct {
+	key    keyFunc
+	groups map[string]countr.Tally
+}
+
+// TallyBy returns a tally which groups records by the key function.
+// Call its Add method with every record, then Counters for the result.
+func TallyBy(key keyFunc) *groupTally {
+	return &groupTally{
+		key:    key,
+		groups: make(map[string]countr.Tally),
+	}
+}
+
+// Add counts the price of a single record against its group
+func (g *groupTally) Add(p *structs.PriceRecord) {
+	k := g.key(p)
+	tally, ok := g.groups[k]
+	if !ok {
+		tally = make(countr.Tally)
+		g.groups[k] = tally
+	}
+	tally.Add(p.Value)
+}
+
+// Counters returns the counted prices of every group, keyed by group
+func (g *groupTally) Counters() map[string]countr.Counter {
+	counters := make(map[string]countr.Counter, len(g.groups))
+	for k, tally := range g.groups {
+		counters[k] = tally.Counter()
+	}
+	return counters
+}
+
+// Partition splits records into one date-ordered series per group, in the shape the
+// ticker-returns pipeline uses, so that purchases can be ranked and classified the same way
+func Partition(records []structs.PriceRecord, key keyFunc) structs.AllPerformers {
+	ap := make(structs.AllPerformers)
+	for i := range records {
+		k := key(&records[i])
+		series, ok := ap[k]
+		if !ok {
+			series = &structs.PriceRecords{}
+			ap[k] = series
+		}
+		*series = append(*series, records[i])
+	}
+
+	for _, series := range ap {
+		sort.Stable(*series)
+	}
+	return ap
+}
@@ -0,0 +1,75 @@
+package organizr
+
+import (
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func TestTallyByProduct(t *testing.T) {
+	g := TallyBy(ProductKey)
+	for i := range RawRecords {
+		g.Add(&RawRecords[i])
+	}
+
+	counters := g.Counters()
+	if len(counters) != 2 {
+		t.Fatalf("TallyBy(ProductKey) should have found 2 products, but found %d", len(counters))
+	}
+
+	type pair struct {
+		Value float64
+		Count float64
+	}
+	want := []pair{{101.14, 6}, {104.7, 3}, {115.02, 1}}
+	got := counters["bed_bath_table:8"]
+	if got.Len() != len(want) {
+		t.Fatalf("Wanted %d unique prices for bed_bath_table:8, but got %d", len(want), got.Len())
+	}
+	for i, w := range want {
+		if got[i].Value != w.Value || got[i].Count != w.Count {
+			t.Errorf("For index %d, wanted %v, but got %v", i, w, got[i])
+		}
+	}
+}
+
+func TestTallyByCustomer(t *testing.T) {
+	g := TallyBy(CustomerKey)
+	for i := range RawRecords {
+		g.Add(&RawRecords[i])
+	}
+
+	got := g.Counters()["d98e2"]
+	var total float64
+	for _, c := range got {
+		total += c.Count
+	}
+	if total != 3 {
+		t.Errorf("Customer d98e2 should have 3 counted prices, but got %3.1f", total)
+	}
+}
+
+func TestPartitionByCustomer(t *testing.T) {
+	ap := Partition(RawRecords, CustomerKey)
+	if len(ap) != 15 {
+		t.Errorf("Partition(CustomerKey) should have found 15 customers, but found %d", len(ap))
+	}
+
+	series := *ap["f4c13"]
+	if len(series) != 3 {
+		t.Fatalf("Customer f4c13 should have 3 records, but got %d", len(series))
+	}
+	for i := 1; i < len(series); i++ {
+		if series[i].Period.Before(series[i-1].Period) {
+			t.Errorf("Records should be in date order, but record %d is on %s and record %d on %s", i-1, series[i-1].Period, i, series[i].Period)
+		}
+	}
+}
+
+func TestPartitionByEntity(t *testing.T) {
+	records := []structs.PriceRecord{{EntityID: "AAAAX", Value: 1}, {EntityID: "BBBBX", Value: 2}, {EntityID: "AAAAX", Value: 3}}
+	ap := Partition(records, EntityKey)
+	if len(ap) != 2 || len(*ap["AAAAX"]) != 2 || len(*ap["BBBBX"]) != 1 {
+		t.Errorf("Partition(EntityKey) should have split the records by ticker, but got %v", ap)
+	}
+}
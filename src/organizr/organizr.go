@@ -80,7 +80,7 @@ func byCustomer(p1, p2 *structs.PriceRecord) bool {
 }
 
 func byDate(p1, p2 *structs.PriceRecord) bool {
-	return p1.PurchaseDate.Before(p2.PurchaseDate)
+	return p1.Period.Before(p2.Period)
 }
 
 func byDocumentNumber(p1, p2 *structs.PriceRecord) bool {
@@ -92,7 +92,7 @@ func byDocumentLineNumber(p1, p2 *structs.PriceRecord) bool {
 }
 
 func byPrice(p1, p2 *structs.PriceRecord) bool {
-	return p1.Price < p2.Price
+	return p1.Value < p2.Value
 }
 
 var ByUuid lessFunc = byUuid
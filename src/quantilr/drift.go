@@ -0,0 +1,246 @@
+package quantilr
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	MismatchedNError       error = errors.New("n-tiles can only be compared with n-tiles of the same N")
+	InvalidThresholdsError error = errors.New("drift thresholds must not be negative, and each warning must not exceed its alert")
+)
+
+// psiFloor stands in for an empty bucket's share, which would otherwise make the Population
+// Stability Index infinite
+const psiFloor = 1e-4
+
+// Verdict is how far a distribution has drifted from its reference
+type Verdict int
+
+const (
+	// Stable distributions can still be compared against the reference boundaries
+	Stable Verdict = iota
+	// Shifted distributions crossed a warning threshold and should be looked at
+	Shifted
+	// Drifted distributions crossed an alert threshold; the boundaries should be refitted
+	Drifted
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Drifted:
+		return "drifted"
+	case Shifted:
+		return "shifted"
+	default:
+		return "stable"
+	}
+}
+
+// DriftThresholds decide the Verdict. A PSI or KS at or above a threshold crosses it.
+type DriftThresholds struct {
+	PSIWarn  float64
+	PSIAlert float64
+	KSWarn   float64
+	KSAlert  float64
+}
+
+// DefaultDriftThresholds uses the usual rule of thumb for the PSI (below 0.1 is stable, above 0.25
+// has drifted), with matching distances for KS
+var DefaultDriftThresholds DriftThresholds = DriftThresholds{
+	PSIWarn:  0.1,
+	PSIAlert: 0.25,
+	KSWarn:   0.1,
+	KSAlert:  0.2,
+}
+
+func (th DriftThresholds) validate() error {
+	if th.PSIWarn < 0 || th.KSWarn < 0 || th.PSIWarn > th.PSIAlert || th.KSWarn > th.KSAlert {
+		return InvalidThresholdsError
+	}
+	return nil
+}
+
+// Verdict classifies a PSI and KS distance
+func (th DriftThresholds) Verdict(psi, ks float64) Verdict {
+	switch {
+	case psi >= th.PSIAlert || ks >= th.KSAlert:
+		return Drifted
+	case psi >= th.PSIWarn || ks >= th.KSWarn:
+		return Shifted
+	default:
+		return Stable
+	}
+}
+
+// BoundaryShift is how far the upper boundary of one n-tile moved
+type BoundaryShift struct {
+	Decile    int8
+	Reference float64
+	Current   float64
+	Shift     float64 // Current - Reference
+}
+
+// BucketChange is how the share of values in one of the reference n-tiles changed
+type BucketChange struct {
+	Decile    int8
+	Reference float64 // the share of the reference values in the n-tile
+	Current   float64 // the share of the current values in the same n-tile
+	Change    float64 // Current - Reference
+	PSI       float64 // the n-tile's contribution to the Population Stability Index
+}
+
+// DriftReport compares a current distribution against its reference
+type DriftReport struct {
+	Reference  NTiles
+	Current    NTiles
+	Boundaries []BoundaryShift
+	Buckets    []BucketChange
+	PSI        float64 // the Population Stability Index over the reference n-tiles
+	KS         float64 // the Kolmogorov-Smirnov distance between the two distributions
+	Verdict    Verdict
+	Thresholds DriftThresholds
+}
+
+// CompareBoundaries lines up the upper boundaries of two fits of the same N. N-tiles which
+// deduplication merged away on either side are left out.
+func CompareBoundaries(reference, current NTiles) ([]BoundaryShift, error) {
+	if reference.N() != current.N() {
+		return nil, MismatchedNError
+	}
+
+	currentUppers := make(map[int8]float64, len(current.Pairs))
+	for _, p := range current.Pairs {
+		currentUppers[p.Decile] = p.Weight
+	}
+
+	var shifts []BoundaryShift
+	for _, p := range reference.Pairs {
+		upper, ok := currentUppers[p.Decile]
+		if !ok {
+			continue
+		}
+		shifts = append(shifts, BoundaryShift{Decile: p.Decile, Reference: p.Weight, Current: upper, Shift: upper - p.Weight})
+	}
+	sort.Slice(shifts, func(i, j int) bool { return shifts[i].Decile < shifts[j].Decile })
+	return shifts, nil
+}
+
+// Drift fits both distributions with cfg and compares them: how the boundaries moved, how the
+// current values spread over the reference n-tiles, and the PSI and KS distance between them.
+// Current values outside the reference range are counted in its lowest or highest n-tile.
+func Drift(reference, current CountedPairs, cfg Config, th DriftThresholds) (*DriftReport, error) {
+	if err := th.validate(); err != nil {
+		return nil, err
+	}
+	// every boundary is kept so that each n-tile can be lined up with its counterpart
+	cfg.Deduplicate = false
+	ref, err := Fit(reference, cfg)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := Fit(current, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{Reference: ref, Current: cur, Thresholds: th}
+	if report.Boundaries, err = CompareBoundaries(ref, cur); err != nil {
+		return nil, err
+	}
+
+	ref.OutOfRange = ClampToRange
+	table, err := ref.Compile()
+	if err != nil {
+		return nil, err
+	}
+	refShares, err := table.shares(reference)
+	if err != nil {
+		return nil, err
+	}
+	curShares, err := table.shares(current)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range table.Pairs() {
+		change := BucketChange{
+			Decile:    p.Decile,
+			Reference: refShares[i],
+			Current:   curShares[i],
+			Change:    curShares[i] - refShares[i],
+		}
+		r, c := math.Max(change.Reference, psiFloor), math.Max(change.Current, psiFloor)
+		change.PSI = (c - r) * math.Log(c/r)
+		report.PSI += change.PSI
+		report.Buckets = append(report.Buckets, change)
+	}
+
+	report.KS = ksDistance(reference, current)
+	report.Verdict = th.Verdict(report.PSI, report.KS)
+	return report, nil
+}
+
+// shares returns the share of the counted values in each of the table's n-tiles
+func (t *Table) shares(c CountedPairs) ([]float64, error) {
+	shares := make([]float64, t.Len())
+	values, counts := c.GetValues(), c.GetCounts()
+
+	var total float64
+	for i, v := range values {
+		label, err := t.Lookup(v)
+		if err != nil {
+			return nil, err
+		}
+		// labels are ascending with the boundaries, so the index is found by search
+		j := sort.Search(len(t.labels), func(k int) bool { return t.labels[k] >= label })
+		shares[j] += counts[i]
+		total += counts[i]
+	}
+	if total == 0 {
+		return nil, NoWeightsError
+	}
+	for i := range shares {
+		shares[i] /= total
+	}
+	return shares, nil
+}
+
+// ksDistance is the largest gap between the cumulative distributions of two sets of counted values
+func ksDistance(a, b CountedPairs) float64 {
+	sortedA, sortedB := sortedCopy(a), sortedCopy(b)
+	aValues, aCounts := sortedA.values, sortedA.counts
+	bValues, bCounts := sortedB.values, sortedB.counts
+
+	var aTotal, bTotal float64
+	for _, c := range aCounts {
+		aTotal += c
+	}
+	for _, c := range bCounts {
+		bTotal += c
+	}
+
+	var distance, aCumulative, bCumulative float64
+	i, j := 0, 0
+	for i < len(aValues) || j < len(bValues) {
+		// step past the next value, on whichever side (or both) it appears
+		var next float64
+		switch {
+		case j == len(bValues) || (i < len(aValues) && aValues[i] <= bValues[j]):
+			next = aValues[i]
+		default:
+			next = bValues[j]
+		}
+		for i < len(aValues) && aValues[i] == next {
+			aCumulative += aCounts[i]
+			i++
+		}
+		for j < len(bValues) && bValues[j] == next {
+			bCumulative += bCounts[j]
+			j++
+		}
+		distance = math.Max(distance, math.Abs(aCumulative/aTotal-bCumulative/bTotal))
+	}
+	return distance
+}
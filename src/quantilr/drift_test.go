@@ -0,0 +1,111 @@
+package quantilr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+func countRange(from, to int) countr.Counter {
+	var values []float64
+	for v := from; v <= to; v++ {
+		values = append(values, float64(v))
+	}
+	return countr.Count(values)
+}
+
+var decileConfig Config = Config{N: 10, Estimator: Empirical}
+
+func TestDriftIdenticalIsStable(t *testing.T) {
+	report, err := Drift(countRange(1, 100), countRange(1, 100), decileConfig, DefaultDriftThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.PSI != 0 || report.KS != 0 || report.Verdict != Stable {
+		t.Errorf("For identical distributions, wanted PSI 0, KS 0 and stable, but got %v, %v and %v", report.PSI, report.KS, report.Verdict)
+	}
+	for _, shift := range report.Boundaries {
+		if shift.Shift != 0 {
+			t.Errorf("For decile %d, wanted no shift, but got %v", shift.Decile, shift.Shift)
+		}
+	}
+}
+
+func TestDriftShiftedDistribution(t *testing.T) {
+	report, err := Drift(countRange(1, 100), countRange(21, 120), decileConfig, DefaultDriftThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Boundaries) != 10 {
+		t.Fatalf("For the boundaries, wanted 10 shifts, but got %v", report.Boundaries)
+	}
+	for _, shift := range report.Boundaries {
+		if shift.Shift != 20 {
+			t.Errorf("For decile %d, wanted a shift of 20, but got %v", shift.Decile, shift.Shift)
+		}
+	}
+
+	// values above the reference range are counted in its top decile
+	wantShares := []float64{0, 0, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.3}
+	var wantPSI float64
+	for i, bucket := range report.Buckets {
+		if math.Abs(bucket.Reference-0.1) > 1e-12 || math.Abs(bucket.Current-wantShares[i]) > 1e-12 {
+			t.Errorf("For decile %d, wanted shares 0.1 and %v, but got %v and %v", bucket.Decile, wantShares[i], bucket.Reference, bucket.Current)
+		}
+		c := math.Max(wantShares[i], psiFloor)
+		wantPSI += (c - 0.1) * math.Log(c/0.1)
+	}
+	if math.Abs(report.PSI-wantPSI) > 1e-9 {
+		t.Errorf("For the PSI, wanted %v, but got %v", wantPSI, report.PSI)
+	}
+	if math.Abs(report.KS-0.2) > 1e-12 {
+		t.Errorf("For the KS distance, wanted 0.2, but got %v", report.KS)
+	}
+	if report.Verdict != Drifted {
+		t.Errorf("For the verdict, wanted %v, but got %v", Drifted, report.Verdict)
+	}
+}
+
+func TestDriftSmallShiftIsStable(t *testing.T) {
+	report, err := Drift(countRange(1, 100), countRange(2, 101), decileConfig, DefaultDriftThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(report.KS-0.01) > 1e-12 || report.Verdict != Stable {
+		t.Errorf("For a shift of one, wanted KS 0.01 and stable, but got %v and %v", report.KS, report.Verdict)
+	}
+}
+
+func TestDriftThresholdsVerdict(t *testing.T) {
+	type testCase struct {
+		psi, ks float64
+		want    Verdict
+	}
+	var testCases []testCase = []testCase{
+		{0.05, 0.05, Stable},
+		{0.1, 0.05, Shifted},
+		{0.05, 0.15, Shifted},
+		{0.3, 0.05, Drifted},
+		{0.05, 0.2, Drifted},
+	}
+	for _, tc := range testCases {
+		if got := DefaultDriftThresholds.Verdict(tc.psi, tc.ks); got != tc.want {
+			t.Errorf("For PSI %v and KS %v, wanted %v, but got %v", tc.psi, tc.ks, tc.want, got)
+		}
+	}
+}
+
+func TestDriftRejectsBadInput(t *testing.T) {
+	bad := DriftThresholds{PSIWarn: 0.3, PSIAlert: 0.2, KSWarn: 0.1, KSAlert: 0.2}
+	if _, err := Drift(countRange(1, 10), countRange(1, 10), decileConfig, bad); err != InvalidThresholdsError {
+		t.Errorf("For a warning above its alert, wanted %v, but got %v", InvalidThresholdsError, err)
+	}
+
+	deciles, _ := NewDeciles(countRange(1, 10), true)
+	quartiles, _ := NewNTiles(countRange(1, 10), 4, true)
+	if _, err := CompareBoundaries(deciles, quartiles); err != MismatchedNError {
+		t.Errorf("For deciles against quartiles, wanted %v, but got %v", MismatchedNError, err)
+	}
+}
@@ -0,0 +1,198 @@
+package quantilr
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+var (
+	UnknownEstimatorError error = errors.New("the quantile estimator is not one quantilr knows")
+	FractionalCountsError error = errors.New("the counts must be whole numbers of observations, not weights")
+)
+
+// Estimator is a method of estimating quantiles from a sample. The zero value is Empirical,
+// which is what quantilr has always used.
+type Estimator int
+
+const (
+	// Empirical is gonum's stat.Empirical: the lowest value whose cumulative weight reaches p
+	Empirical Estimator = iota
+	// LinInterp is gonum's stat.LinInterp: linear interpolation of the empirical distribution
+	LinInterp
+	// HyndmanFan1 to HyndmanFan9 are the nine sample quantile definitions of Hyndman and Fan
+	// (1996), numbered as they are by R's quantile(x, probs, type). Types 1 to 3 are
+	// discontinuous, 4 to 9 interpolate between order statistics; type 7 is R's default.
+	HyndmanFan1
+	HyndmanFan2
+	HyndmanFan3
+	HyndmanFan4
+	HyndmanFan5
+	HyndmanFan6
+	HyndmanFan7
+	HyndmanFan8
+	HyndmanFan9
+)
+
+func (e Estimator) String() string {
+	switch e {
+	case Empirical:
+		return "Empirical"
+	case LinInterp:
+		return "LinInterp"
+	default:
+		if e.isHyndmanFan() {
+			return "HyndmanFan" + strconv.Itoa(e.hyndmanFanType())
+		}
+		return "Unknown"
+	}
+}
+
+func (e Estimator) isHyndmanFan() bool {
+	return e >= HyndmanFan1 && e <= HyndmanFan9
+}
+
+func (e Estimator) hyndmanFanType() int {
+	return int(e-HyndmanFan1) + 1
+}
+
+// Valid reports whether the estimator is one quantilr knows
+func (e Estimator) Valid() bool {
+	return e == Empirical || e == LinInterp || e.isHyndmanFan()
+}
+
+// Quantiles returns the value at each of the cumulative probabilities probs, which must be
+// within [0, 1]. Counts are treated as the number of times each value was observed, or as its
+// weight when counted with countr.CountWeighted. Empirical and LinInterp use the weighted
+// distribution directly, but the Hyndman-Fan types take the total count as the number of
+// observations, which is meaningless for weights: Fit and BoundaryIntervals reject them with
+// FractionalCountsError.
+func (e Estimator) Quantiles(c CountedPairs, probs []float64) (quantiles, pointValues []float64) {
+	quantiles = make([]float64, len(probs))
+	pointValues = make([]float64, len(probs))
+
+	// the values must be in increasing order to collect quantiles; c itself is left as it was
+	sorted := sortedCopy(c)
+	values, weights := sorted.values, sorted.counts
+
+	for i, p := range probs {
+		quantiles[i] = p
+		switch {
+		case p >= 1 && !e.isHyndmanFan():
+			// gonum accumulates the weights up to their total, which rounding can fall just
+			// short of with fractional weights, so the top is found directly
+			pointValues[i] = highestWeighted(values, weights)
+		case e == LinInterp:
+			pointValues[i] = stat.Quantile(p, stat.LinInterp, values, weights)
+		case e.isHyndmanFan():
+			pointValues[i] = hyndmanFan(e.hyndmanFanType(), p, values, weights)
+		default:
+			pointValues[i] = stat.Quantile(p, stat.Empirical, values, weights)
+		}
+	}
+
+	return quantiles, pointValues
+}
+
+// highestWeighted returns the highest of the sorted values to carry any weight
+func highestWeighted(values, weights []float64) float64 {
+	for i := len(values) - 1; i > 0; i-- {
+		if weights[i] > 0 {
+			return values[i]
+		}
+	}
+	return values[0]
+}
+
+// wholeCounts reports whether every count is a whole number of observations
+func wholeCounts(counts []float64) bool {
+	for _, c := range counts {
+		if c != math.Trunc(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// hyndmanFanFuzz absorbs rounding in n*p, as R does
+const hyndmanFanFuzz = 4 * 2.220446049250313e-16
+
+// hyndmanFan follows R's quantile.default for the given type, over sorted values with counts
+func hyndmanFan(t int, p float64, values, counts []float64) float64 {
+	var n float64
+	for _, c := range counts {
+		n += c
+	}
+
+	var j, h float64
+	if t <= 3 {
+		nppm := n * p
+		if t == 3 {
+			nppm -= 0.5
+		}
+		j = math.Floor(nppm + hyndmanFanFuzz)
+		switch t {
+		case 1:
+			if nppm > j {
+				h = 1
+			}
+		case 2:
+			h = 0.5
+			if nppm > j {
+				h = 1
+			}
+		case 3:
+			if nppm != j || math.Mod(j, 2) == 1 {
+				h = 1
+			}
+		}
+	} else {
+		// the plotting positions (k - a) / (n + 1 - a - b) of each type
+		var a, b float64
+		switch t {
+		case 4:
+			a, b = 0, 1
+		case 5:
+			a, b = 0.5, 0.5
+		case 6:
+			a, b = 0, 0
+		case 7:
+			a, b = 1, 1
+		case 8:
+			a, b = 1.0/3, 1.0/3
+		case 9:
+			a, b = 3.0/8, 3.0/8
+		}
+		nppm := a + p*(n+1-a-b)
+		j = math.Floor(nppm + hyndmanFanFuzz)
+		h = nppm - j
+		if math.Abs(h) < hyndmanFanFuzz {
+			h = 0
+		}
+	}
+
+	lo, hi := orderStatistic(j, values, counts), orderStatistic(j+1, values, counts)
+	switch {
+	case h == 1:
+		return hi
+	case h > 0 && h < 1 && lo != hi:
+		return (1-h)*lo + h*hi
+	default:
+		return lo
+	}
+}
+
+// orderStatistic returns the kth smallest observation (1-indexed), clamped to the first and
+// last observations
+func orderStatistic(k float64, values, counts []float64) float64 {
+	var cumulative float64
+	for i, c := range counts {
+		cumulative += c
+		if cumulative >= k {
+			return values[i]
+		}
+	}
+	return values[len(values)-1]
+}
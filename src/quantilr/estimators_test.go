@@ -0,0 +1,198 @@
+package quantilr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+// reference values from R, e.g. quantile(c(1, 2, 2, 3, 5, 8, 13, 21), c(0.1, 0.25, 0.5, 0.9), type = 7)
+func TestHyndmanFanMatchesR(t *testing.T) {
+	x := countr.Count([]float64{1, 2, 2, 3, 5, 8, 13, 21})
+	p := []float64{0.1, 0.25, 0.5, 0.9}
+
+	type testCase struct {
+		Estimator Estimator
+		Want      []float64
+	}
+	var testCases []testCase = []testCase{
+		{HyndmanFan1, []float64{1, 2, 3, 21}},
+		{HyndmanFan2, []float64{1, 2, 4, 21}},
+		{HyndmanFan3, []float64{1, 2, 3, 13}},
+		{HyndmanFan4, []float64{1, 2, 3, 14.6}},
+		{HyndmanFan5, []float64{1.3, 2, 4, 18.6}},
+		{HyndmanFan6, []float64{1, 2, 4, 21}},
+		{HyndmanFan7, []float64{1.7, 2, 4, 15.4}},
+		{HyndmanFan8, []float64{1.166667, 2, 4, 19.666667}},
+		{HyndmanFan9, []float64{1.2, 2, 4, 19.4}},
+	}
+
+	for _, tc := range testCases {
+		_, got := tc.Estimator.Quantiles(x, p)
+		for i, w := range tc.Want {
+			if math.Abs(got[i]-w) > 1e-6 {
+				t.Errorf("For %s at p = %3.2f, wanted %f, but got %f", tc.Estimator, p[i], w, got[i])
+			}
+		}
+	}
+}
+
+// reference values from R, quantile(v, seq(0.1, 1, 0.1), type = t), for the tied values of c
+func TestHyndmanFanDecilesMatchR(t *testing.T) {
+	type testCase struct {
+		Estimator Estimator
+		Want      []float64
+	}
+	var testCases []testCase = []testCase{
+		{HyndmanFan1, []float64{1, 1, 1, 1.5, 2, 4, 6, 8, 10, 12}},
+		{HyndmanFan2, []float64{1, 1, 1, 1.5, 2, 4, 6, 8, 10, 12}},
+		{HyndmanFan3, []float64{1, 1, 1, 1.5, 1.5, 4, 6, 8, 10, 12}},
+		{HyndmanFan4, []float64{1, 1, 1, 1.5, 1.75, 3.6, 5.7, 7.8, 9.9, 12}},
+		{HyndmanFan5, []float64{1, 1, 1, 1.5, 2, 4.1, 6.2, 8.3, 10.4, 12}},
+		{HyndmanFan6, []float64{1, 1, 1, 1.5, 2, 4.2, 6.4, 8.6, 10.8, 12}},
+		{HyndmanFan7, []float64{1, 1, 1, 1.5, 2, 4, 6, 8, 10, 12}},
+		{HyndmanFan8, []float64{1, 1, 1, 1.5, 2, 4.133333, 6.266667, 8.4, 10.533333, 12}},
+		{HyndmanFan9, []float64{1, 1, 1, 1.5, 2, 4.125, 6.25, 8.375, 10.5, 12}},
+	}
+
+	for _, tc := range testCases {
+		got, err := Fit(c, Config{N: 10, Estimator: tc.Estimator})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Estimator != tc.Estimator {
+			t.Errorf("Fit should have recorded the estimator %s, but recorded %s", tc.Estimator, got.Estimator)
+		}
+		for i, w := range tc.Want {
+			if math.Abs(got.Pairs[i].Weight-w) > 1e-6 {
+				t.Errorf("For %s decile %d, wanted %f, but got %f", tc.Estimator, i+1, w, got.Pairs[i].Weight)
+			}
+		}
+	}
+}
+
+func TestEmpiricalIsHyndmanFan1(t *testing.T) {
+	_, empirical := Empirical.Quantiles(c, probs)
+	_, hf1 := HyndmanFan1.Quantiles(c, probs)
+	for i := range probs {
+		if empirical[i] != hf1[i] {
+			t.Errorf("For p = %2.1f, Empirical gave %f but HyndmanFan1 gave %f", probs[i], empirical[i], hf1[i])
+		}
+	}
+}
+
+func TestLinInterpIsHyndmanFan4(t *testing.T) {
+	x := countr.Count([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	p := []float64{0.1, 0.25, 0.5, 0.73, 0.95}
+
+	_, lin := LinInterp.Quantiles(x, p)
+	_, hf4 := HyndmanFan4.Quantiles(x, p)
+	for i := range p {
+		if math.Abs(lin[i]-hf4[i]) > 1e-9 {
+			t.Errorf("For p = %3.2f, LinInterp gave %f but HyndmanFan4 gave %f", p[i], lin[i], hf4[i])
+		}
+	}
+}
+
+func TestFitRejectsUnknownEstimator(t *testing.T) {
+	if _, err := Fit(c, Config{N: 10, Estimator: HyndmanFan9 + 1}); err != UnknownEstimatorError {
+		t.Errorf("Fit should have returned UnknownEstimatorError, but got %v", err)
+	}
+}
+
+func TestFitRejectsHyndmanFanOnWeights(t *testing.T) {
+	weighted, err := countr.CountWeighted([]float64{1, 2, 3}, []float64{0.5, 1.25, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		estimator Estimator
+		counts    countr.Counter
+		wantErr   error
+	}
+	var testCases []testCase = []testCase{
+		{HyndmanFan7, weighted, FractionalCountsError},
+		{HyndmanFan1, weighted, FractionalCountsError},
+		{HyndmanFan7, c, nil},
+		{Empirical, weighted, nil},
+		{LinInterp, weighted, nil},
+	}
+
+	for _, tc := range testCases {
+		if _, err := Fit(tc.counts, Config{N: 4, Estimator: tc.estimator}); err != tc.wantErr {
+			t.Errorf("For %v on %v, wanted %v, but got %v", tc.estimator, tc.counts, tc.wantErr, err)
+		}
+	}
+}
+
+func TestFitTakesFractionalWeights(t *testing.T) {
+	var weights countr.Counter
+	for v := 1; v <= 100; v++ {
+		weights = append(weights, countr.CounterPair{Value: float64(v), Count: 0.4})
+	}
+	weights = append(weights, countr.CounterPair{Value: 101, Count: 0})
+
+	for _, estimator := range []Estimator{Empirical, LinInterp} {
+		d, err := Fit(weights, Config{N: 10, Estimator: estimator})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if top := d.Pairs[len(d.Pairs)-1]; top.Weight != 100 {
+			t.Errorf("For %v, wanted the top boundary at the highest weighted value 100, but got %v", estimator, top.Weight)
+		}
+	}
+}
+
+func TestEntryPointsTakeAnEstimator(t *testing.T) {
+	x := countr.Count([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	_, want := HyndmanFan7.Quantiles(x, probs)
+
+	_, got := Quantiles(x, probs, HyndmanFan7)
+	d, err := NewDeciles(x, false, HyndmanFan7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Sort()
+	if d.Estimator != HyndmanFan7 {
+		t.Errorf("NewDeciles should have recorded HyndmanFan7, but recorded %v", d.Estimator)
+	}
+	for i := range probs {
+		if got[i] != want[i] || d.Pairs[i].Weight != want[i] {
+			t.Errorf("For p = %2.1f, wanted %f, but Quantiles gave %f and NewDeciles %f", probs[i], want[i], got[i], d.Pairs[i].Weight)
+		}
+	}
+
+	if _, err := NewNTiles(x, 4, false, HyndmanFan9+1); err != UnknownEstimatorError {
+		t.Errorf("NewNTiles should have returned UnknownEstimatorError, but got %v", err)
+	}
+	if d, _ := NewDeciles(x, false); d.Estimator != Empirical {
+		t.Errorf("NewDeciles should default to Empirical, but used %v", d.Estimator)
+	}
+}
+
+func TestFittingLeavesTheInputInOrder(t *testing.T) {
+	var reversed countr.Counter
+	for i := len(c) - 1; i >= 0; i-- {
+		reversed = append(reversed, c[i])
+	}
+	want := append(countr.Counter(nil), reversed...)
+
+	if _, err := Fit(reversed, Config{N: 10, Estimator: HyndmanFan7}); err != nil {
+		t.Fatal(err)
+	}
+	Quantiles(reversed, []float64{0.5}, LinInterp)
+	if _, err := Drift(reversed, reversed, Config{N: 10}, DefaultDriftThresholds); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BoundaryIntervals(reversed, 10, DefaultIntervalConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range want {
+		if reversed[i] != want[i] {
+			t.Fatalf("For pair %d, wanted the input left as %v, but got %v", i, want[i], reversed[i])
+		}
+	}
+}
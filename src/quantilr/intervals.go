@@ -0,0 +1,265 @@
+package quantilr
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+var (
+	InvalidConfidenceError error = errors.New("the confidence level must be between 0 and 1")
+	InvalidResamplesError  error = errors.New("at least one bootstrap resample must be drawn")
+	InvalidSampleSizeError error = errors.New("the bootstrap sample size must not be negative")
+	UnknownIntervalError   error = errors.New("the interval method is not one of OrderStatistic or Bootstrap")
+)
+
+// IntervalMethod decides how the uncertainty of a boundary is estimated
+type IntervalMethod int
+
+const (
+	// OrderStatistic gives distribution-free intervals between two observations, chosen from the
+	// binomial distribution of the number of observations below the true quantile. With few
+	// observations the interval is as wide as the data allows, and may cover less than asked for.
+	OrderStatistic IntervalMethod = iota
+	// Bootstrap gives percentile intervals over boundaries refitted on seeded resamples
+	Bootstrap
+)
+
+func (m IntervalMethod) String() string {
+	switch m {
+	case OrderStatistic:
+		return "order statistic"
+	case Bootstrap:
+		return "bootstrap"
+	default:
+		return "unknown"
+	}
+}
+
+// IntervalConfig decides how boundary intervals are estimated. OrderStatistic takes counts as the
+// number of times each value was observed, and like the Hyndman-Fan estimators rejects counts
+// that are not whole numbers with FractionalCountsError. Bootstrap takes them as weights, drawing
+// each resample of SampleSize values in proportion to them, however large or small they are.
+type IntervalConfig struct {
+	Method     IntervalMethod
+	Confidence float64   // e.g. 0.95 for 95% intervals
+	Estimator  Estimator // how each boundary, and each bootstrap boundary, is estimated
+	Resamples  int       // the number of bootstrap resamples
+	SampleSize int       // the values drawn into each bootstrap resample; 0 is the number of distinct values
+	Seed       uint64    // the bootstrap's random seed
+}
+
+var DefaultIntervalConfig IntervalConfig = IntervalConfig{
+	Method:     OrderStatistic,
+	Confidence: 0.95,
+	Estimator:  Empirical,
+	Resamples:  1000,
+	Seed:       123456,
+}
+
+// Interval is the uncertainty around the upper boundary of one n-tile
+type Interval struct {
+	Decile      int8
+	Probability float64 // the cumulative probability at the boundary
+	Estimate    float64 // the boundary, as fitted
+	Lower       float64
+	Upper       float64
+	Coverage    float64 // the confidence the interval actually holds, which can fall short of that asked for
+}
+
+// Contains reports whether v falls within the interval
+func (iv Interval) Contains(v float64) bool {
+	return v >= iv.Lower && v <= iv.Upper
+}
+
+// Intervals holds the interval of every boundary, lowest first
+type Intervals []Interval
+
+// BoundaryIntervals estimates an interval around the upper boundary of each of n n-tiles
+func BoundaryIntervals(c CountedPairs, n int, cfg IntervalConfig) (Intervals, error) {
+	if n < 1 || n > MaxN {
+		return nil, InvalidNError
+	}
+	if cfg.Confidence <= 0 || cfg.Confidence >= 1 {
+		return nil, InvalidConfidenceError
+	}
+	if !cfg.Estimator.Valid() {
+		return nil, UnknownEstimatorError
+	}
+	if c.Len() == 0 {
+		return nil, NoDataError
+	}
+	if (cfg.Method == OrderStatistic || cfg.Estimator.isHyndmanFan()) && !wholeCounts(c.GetCounts()) {
+		return nil, FractionalCountsError
+	}
+
+	probs, estimates := cfg.Estimator.Quantiles(c, Probabilities(n))
+	intervals := make(Intervals, n)
+	for i := range intervals {
+		intervals[i] = Interval{Decile: int8(i + 1), Probability: probs[i], Estimate: estimates[i]}
+	}
+
+	sorted := sortedCopy(c)
+	values, counts := sorted.values, sorted.counts
+	switch cfg.Method {
+	case OrderStatistic:
+		observations := 0
+		for _, count := range counts {
+			observations += int(count)
+		}
+		if observations == 0 {
+			return nil, NoWeightsError
+		}
+		for i := range intervals {
+			lower, upper, coverage := orderStatisticRanks(observations, probs[i], 1-cfg.Confidence)
+			intervals[i].Lower = orderStatistic(float64(lower), values, counts)
+			intervals[i].Upper = orderStatistic(float64(upper), values, counts)
+			intervals[i].Coverage = coverage
+		}
+	case Bootstrap:
+		if cfg.Resamples < 1 {
+			return nil, InvalidResamplesError
+		}
+		if cfg.SampleSize < 0 {
+			return nil, InvalidSampleSizeError
+		}
+		if err := bootstrap(intervals, values, counts, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, UnknownIntervalError
+	}
+
+	return intervals, nil
+}
+
+// orderStatisticRanks finds the ranks l and u, as close together as allowed, for which the
+// number of n observations below the p quantile, B ~ Binomial(n, p), has P(B < l) <= alpha/2 and
+// P(B >= u) <= alpha/2. The interval between the lth and uth smallest observations then covers
+// the quantile with probability P(l <= B < u), which is returned as the coverage.
+func orderStatisticRanks(n int, p, alpha float64) (lower, upper int, coverage float64) {
+	// cdf[k] is P(B < k)
+	cdf := make([]float64, n+2)
+	for k := 0; k <= n; k++ {
+		cdf[k+1] = cdf[k] + binomialProb(k, n, p)
+	}
+
+	lower, upper = 1, n
+	for k := 1; k <= n; k++ {
+		if cdf[k] <= alpha/2 {
+			lower = k
+		}
+	}
+	for k := n; k >= lower; k-- {
+		if 1-cdf[k] <= alpha/2 {
+			upper = k
+		}
+	}
+	return lower, upper, math.Min(cdf[upper]-cdf[lower], 1)
+}
+
+// binomialProb is P(B = k) for B ~ Binomial(n, p)
+func binomialProb(k, n int, p float64) float64 {
+	switch {
+	case p <= 0:
+		if k == 0 {
+			return 1
+		}
+		return 0
+	case p >= 1:
+		if k == n {
+			return 1
+		}
+		return 0
+	}
+	nf, kf := float64(n), float64(k)
+	logChoose, _ := math.Lgamma(nf + 1)
+	lk, _ := math.Lgamma(kf + 1)
+	lnk, _ := math.Lgamma(nf - kf + 1)
+	return math.Exp(logChoose - lk - lnk + kf*math.Log(p) + (nf-kf)*math.Log1p(-p))
+}
+
+// bootstrap fills in percentile intervals from boundaries refitted on cfg.Resamples resamples of
+// cfg.SampleSize values, drawn with replacement in proportion to their counts
+func bootstrap(intervals Intervals, values, counts []float64, cfg IntervalConfig) error {
+	var total float64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return NoWeightsError
+	}
+	size := cfg.SampleSize
+	if size == 0 {
+		size = len(values)
+	}
+
+	draw := distuv.NewCategorical(counts, rand.NewSource(cfg.Seed))
+	probs := make([]float64, len(intervals))
+	for i, iv := range intervals {
+		probs[i] = iv.Probability
+	}
+
+	// boundaries[i] holds the ith boundary of every resample
+	boundaries := make([][]float64, len(intervals))
+	for i := range boundaries {
+		boundaries[i] = make([]float64, cfg.Resamples)
+	}
+	drawn := make([]float64, len(values))
+	r := counted{values: make([]float64, 0, len(values)), counts: make([]float64, 0, len(counts))}
+	for b := 0; b < cfg.Resamples; b++ {
+		for i := range drawn {
+			drawn[i] = 0
+		}
+		for k := 0; k < size; k++ {
+			drawn[int(draw.Rand())]++
+		}
+		// values are in increasing order, so the resample is too; values never drawn are left out
+		r.values, r.counts = r.values[:0], r.counts[:0]
+		for i, count := range drawn {
+			if count > 0 {
+				r.values = append(r.values, values[i])
+				r.counts = append(r.counts, count)
+			}
+		}
+		_, estimates := cfg.Estimator.Quantiles(r, probs)
+		for i, e := range estimates {
+			boundaries[i][b] = e
+		}
+	}
+
+	alpha := 1 - cfg.Confidence
+	for i := range intervals {
+		sort.Float64s(boundaries[i])
+		intervals[i].Lower = percentile(boundaries[i], alpha/2)
+		intervals[i].Upper = percentile(boundaries[i], 1-alpha/2)
+		intervals[i].Coverage = cfg.Confidence
+	}
+	return nil
+}
+
+// percentile returns the lowest of the sorted values with at least the share p at or below it
+func percentile(sorted []float64, p float64) float64 {
+	k := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if k < 0 {
+		k = 0
+	}
+	return sorted[k]
+}
+
+// Near returns the interval of the boundary whose uncertainty band v falls within, if any. The
+// highest boundary is left out, as it separates no n-tiles.
+func (ivs Intervals) Near(v float64) (Interval, bool) {
+	for _, iv := range ivs {
+		if iv.Probability >= 1 {
+			continue
+		}
+		if iv.Contains(v) {
+			return iv, true
+		}
+	}
+	return Interval{}, false
+}
@@ -0,0 +1,189 @@
+package quantilr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+func TestOrderStatisticRanksForTheMedian(t *testing.T) {
+	// the textbook 95% interval for the median of 100 observations runs from the 40th to the 61st
+	lower, upper, coverage := orderStatisticRanks(100, 0.5, 0.05)
+	if lower != 40 || upper != 61 {
+		t.Errorf("For the median of 100, wanted ranks 40 and 61, but got %d and %d", lower, upper)
+	}
+	if math.Abs(coverage-0.9648) > 1e-4 {
+		t.Errorf("For the median of 100, wanted coverage 0.9648, but got %v", coverage)
+	}
+}
+
+func TestOrderStatisticIntervals(t *testing.T) {
+	intervals, err := BoundaryIntervals(countRange(1, 100), 10, DefaultIntervalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) != 10 {
+		t.Fatalf("For deciles, wanted 10 intervals, but got %d", len(intervals))
+	}
+
+	median := intervals[4]
+	if median.Decile != 5 || median.Estimate != 50 || median.Lower != 40 || median.Upper != 61 {
+		t.Errorf("For decile 5, wanted 50 within [40, 61], but got %v within [%v, %v]", median.Estimate, median.Lower, median.Upper)
+	}
+	for _, iv := range intervals {
+		if !iv.Contains(iv.Estimate) {
+			t.Errorf("For decile %d, wanted the interval [%v, %v] to contain %v", iv.Decile, iv.Lower, iv.Upper, iv.Estimate)
+		}
+		if iv.Probability < 1 && iv.Coverage < 0.95 {
+			t.Errorf("For decile %d, wanted coverage of at least 0.95, but got %v", iv.Decile, iv.Coverage)
+		}
+	}
+}
+
+func TestOrderStatisticIntervalsReportShortCoverage(t *testing.T) {
+	intervals, err := BoundaryIntervals(countRange(1, 5), 10, DefaultIntervalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with five observations, the first decile can only be pinned between the 1st and 3rd
+	first := intervals[0]
+	if first.Lower != 1 || first.Upper != 3 {
+		t.Errorf("For decile 1, wanted [1, 3], but got [%v, %v]", first.Lower, first.Upper)
+	}
+	if want := 5*0.1*math.Pow(0.9, 4) + 10*0.01*math.Pow(0.9, 3); math.Abs(first.Coverage-want) > 1e-12 {
+		t.Errorf("For decile 1, wanted coverage %v, but got %v", want, first.Coverage)
+	}
+}
+
+func TestBootstrapIntervals(t *testing.T) {
+	cfg := DefaultIntervalConfig
+	cfg.Method = Bootstrap
+	cfg.Resamples = 200
+
+	intervals, err := BoundaryIntervals(countRange(1, 100), 10, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := BoundaryIntervals(countRange(1, 100), 10, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, iv := range intervals {
+		if iv != again[i] {
+			t.Errorf("For decile %d, wanted the same interval from the same seed, but got %v and %v", iv.Decile, iv, again[i])
+		}
+		if iv.Probability < 1 && (!iv.Contains(iv.Estimate) || iv.Lower == iv.Upper) {
+			t.Errorf("For decile %d, wanted a band around %v, but got [%v, %v]", iv.Decile, iv.Estimate, iv.Lower, iv.Upper)
+		}
+		if iv.Coverage != cfg.Confidence {
+			t.Errorf("For decile %d, wanted coverage %v, but got %v", iv.Decile, cfg.Confidence, iv.Coverage)
+		}
+	}
+}
+
+func TestIntervalsNear(t *testing.T) {
+	intervals, err := BoundaryIntervals(countRange(1, 100), 10, DefaultIntervalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if iv, ok := intervals.Near(intervals[4].Estimate); !ok || !iv.Contains(intervals[4].Estimate) {
+		t.Errorf("For the median boundary itself, wanted it to be near a boundary, but got %v, %v", iv, ok)
+	}
+	for _, v := range []float64{-5, 100} {
+		if iv, ok := intervals.Near(v); ok {
+			t.Errorf("For %v, wanted it to be near no boundary, but got decile %d", v, iv.Decile)
+		}
+	}
+}
+
+func TestBoundaryIntervalsRejectsBadInput(t *testing.T) {
+	type testCase struct {
+		name string
+		n    int
+		cfg  IntervalConfig
+		want error
+	}
+	bootstrap := DefaultIntervalConfig
+	bootstrap.Method, bootstrap.Resamples = Bootstrap, 0
+	confidence := DefaultIntervalConfig
+	confidence.Confidence = 1
+	method := DefaultIntervalConfig
+	method.Method = IntervalMethod(9)
+	sampleSize := DefaultIntervalConfig
+	sampleSize.Method, sampleSize.SampleSize = Bootstrap, -1
+
+	var testCases []testCase = []testCase{
+		{"bad n", 0, DefaultIntervalConfig, InvalidNError},
+		{"bad confidence", 10, confidence, InvalidConfidenceError},
+		{"no resamples", 10, bootstrap, InvalidResamplesError},
+		{"unknown method", 10, method, UnknownIntervalError},
+		{"negative sample size", 10, sampleSize, InvalidSampleSizeError},
+	}
+	for _, tc := range testCases {
+		if _, err := BoundaryIntervals(countRange(1, 10), tc.n, tc.cfg); err != tc.want {
+			t.Errorf("For %s, wanted %v, but got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestBoundaryIntervalsRejectsUnusableCounts(t *testing.T) {
+	type testCase struct {
+		name   string
+		counts countr.Counter
+		cfg    IntervalConfig
+		want   error
+	}
+	weighted := countr.Counter{{Value: 1, Count: 0.5}, {Value: 2, Count: 1.5}}
+	hyndmanFan := DefaultIntervalConfig
+	hyndmanFan.Method, hyndmanFan.Estimator = Bootstrap, HyndmanFan7
+	bootstrap := DefaultIntervalConfig
+	bootstrap.Method = Bootstrap
+
+	var testCases []testCase = []testCase{
+		{"no observations", countr.Counter{{Value: 1, Count: 0}, {Value: 2, Count: 0}}, DefaultIntervalConfig, NoWeightsError},
+		{"weights for order statistics", weighted, DefaultIntervalConfig, FractionalCountsError},
+		{"weights for Hyndman-Fan", weighted, hyndmanFan, FractionalCountsError},
+		{"weights for the bootstrap", weighted, bootstrap, nil},
+	}
+	for _, tc := range testCases {
+		if _, err := BoundaryIntervals(tc.counts, 4, tc.cfg); err != tc.want {
+			t.Errorf("For %s, wanted %v, but got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestBootstrapDrawsAFixedSampleFromWeights(t *testing.T) {
+	cfg := DefaultIntervalConfig
+	cfg.Method = Bootstrap
+	cfg.Resamples = 200
+
+	type testCase struct {
+		name  string
+		scale float64
+	}
+	var testCases []testCase = []testCase{
+		{"fractional weights", 0.4},
+		{"market caps", 1e12},
+	}
+	for _, tc := range testCases {
+		var weights countr.Counter
+		for v := 1; v <= 100; v++ {
+			weights = append(weights, countr.CounterPair{Value: float64(v), Count: tc.scale})
+		}
+
+		got, err := BoundaryIntervals(weights, 10, cfg)
+		if err != nil {
+			t.Fatalf("For %s, wanted intervals, but got %v", tc.name, err)
+		}
+		// equal weights of any size give the same resamples as counting every value once
+		want, _ := BoundaryIntervals(countRange(1, 100), 10, cfg)
+		for i := range want {
+			if got[i].Lower != want[i].Lower || got[i].Upper != want[i].Upper {
+				t.Errorf("For %s and decile %d, wanted [%v, %v], but got [%v, %v]", tc.name, want[i].Decile, want[i].Lower, want[i].Upper, got[i].Lower, got[i].Upper)
+			}
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package quantilr
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var CorruptNTilesError error = errors.New("the saved n-tiles are not valid")
+
+// timeNow is stubbed out by tests which need a fixed fit date
+var timeNow = time.Now
+
+// Fingerprint identifies the counted values n-tiles were fitted on, so that saved boundaries
+// can be matched back to their reference data. The order of the pairs does not matter.
+func Fingerprint(c CountedPairs) string {
+	values, counts := c.GetValues(), c.GetCounts()
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	h := sha256.New()
+	var buf [8]byte
+	for _, i := range order {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(values[i]))
+		h.Write(buf[:])
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(counts[i]))
+		h.Write(buf[:])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// MarshalText writes the estimator's name, as String does
+func (e Estimator) MarshalText() ([]byte, error) {
+	if !e.Valid() {
+		return nil, UnknownEstimatorError
+	}
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText reads an estimator's name, as String writes it
+func (e *Estimator) UnmarshalText(text []byte) error {
+	for candidate := Empirical; candidate <= HyndmanFan9; candidate++ {
+		if candidate.String() == string(text) {
+			*e = candidate
+			return nil
+		}
+	}
+	return UnknownEstimatorError
+}
+
+type savedBoundary struct {
+	Decile int8    `json:"decile"`
+	Upper  float64 `json:"upper"`
+}
+
+// savedNTiles is the form NTiles are saved in
+type savedNTiles struct {
+	Estimator     Estimator       `json:"estimator"`
+	Fingerprint   string          `json:"fingerprint,omitempty"`
+	FittedAt      time.Time       `json:"fittedAt"`
+	OutOfRange    RangePolicy     `json:"outOfRange"`
+	Min           *float64        `json:"min,omitempty"`
+	Deduplicated  bool            `json:"deduplicated"`
+	Probabilities []float64       `json:"probabilities"`
+	Boundaries    []savedBoundary `json:"boundaries"`
+}
+
+func (d NTiles) saved() savedNTiles {
+	pairs := make([]DecilePair, len(d.Pairs))
+	copy(pairs, d.Pairs)
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Decile < pairs[j].Decile })
+
+	s := savedNTiles{
+		Estimator:     d.Estimator,
+		Fingerprint:   d.Fingerprint,
+		FittedAt:      d.FittedAt,
+		OutOfRange:    d.OutOfRange,
+		Deduplicated:  d.isDeduped,
+		Probabilities: d.Probabilities,
+		Boundaries:    make([]savedBoundary, len(pairs)),
+	}
+	if min, ok := d.Min(); ok {
+		s.Min = &min
+	}
+	for i, p := range pairs {
+		s.Boundaries[i] = savedBoundary{Decile: p.Decile, Upper: p.Weight}
+	}
+	return s
+}
+
+// load checks the saved n-tiles and restores them, sorted. They count as deduplicated when no
+// two boundaries share a value, which must be the case if they were saved deduplicated.
+func (s savedNTiles) load() (NTiles, error) {
+	if len(s.Boundaries) == 0 {
+		return NTiles{}, fmt.Errorf("%w: no boundaries", CorruptNTilesError)
+	}
+	if !s.Estimator.Valid() {
+		return NTiles{}, UnknownEstimatorError
+	}
+	if !s.OutOfRange.Valid() {
+		return NTiles{}, UnknownRangePolicyError
+	}
+	for i, p := range s.Probabilities {
+		if p < 0 || p > 1 || math.IsNaN(p) {
+			return NTiles{}, fmt.Errorf("%w: probability %v is outside 0 to 1", CorruptNTilesError, p)
+		}
+		if i > 0 && p < s.Probabilities[i-1] {
+			return NTiles{}, fmt.Errorf("%w: probability %v falls below the %v before it", CorruptNTilesError, p, s.Probabilities[i-1])
+		}
+	}
+
+	d := NTiles{
+		Pairs:         make([]DecilePair, len(s.Boundaries)),
+		Probabilities: s.Probabilities,
+		Estimator:     s.Estimator,
+		Fingerprint:   s.Fingerprint,
+		FittedAt:      s.FittedAt,
+		OutOfRange:    s.OutOfRange,
+	}
+	if s.Min != nil {
+		d.min, d.hasMin = *s.Min, true
+	}
+	for i, b := range s.Boundaries {
+		d.Pairs[i] = DecilePair{Decile: b.Decile, Weight: b.Upper}
+	}
+	d.Sort()
+
+	n := d.N()
+	duplicates := false
+	for i, p := range d.Pairs {
+		if p.Decile < 1 || int(p.Decile) > n {
+			return NTiles{}, fmt.Errorf("%w: decile %d is outside 1 to %d", CorruptNTilesError, p.Decile, n)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := d.Pairs[i-1]
+		switch {
+		case p.Decile == prev.Decile:
+			return NTiles{}, fmt.Errorf("%w: decile %d appears twice", CorruptNTilesError, p.Decile)
+		case p.Weight < prev.Weight:
+			return NTiles{}, fmt.Errorf("%w: decile %d ends below decile %d", CorruptNTilesError, p.Decile, prev.Decile)
+		case p.Weight == prev.Weight:
+			duplicates = true
+		}
+	}
+	if d.hasMin && d.min > d.Pairs[0].Weight {
+		return NTiles{}, fmt.Errorf("%w: the smallest value is above the lowest boundary", CorruptNTilesError)
+	}
+	if s.Deduplicated && duplicates {
+		return NTiles{}, fmt.Errorf("%w: saved as deduplicated, but boundaries share a value", CorruptNTilesError)
+	}
+	d.isDeduped = !duplicates
+	return d, nil
+}
+
+// MarshalJSON saves the boundaries along with how and when they were fitted
+func (d NTiles) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.saved())
+}
+
+// UnmarshalJSON restores boundaries saved by MarshalJSON, checking they are still valid
+func (d *NTiles) UnmarshalJSON(b []byte) error {
+	var s savedNTiles
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	loaded, err := s.load()
+	if err != nil {
+		return err
+	}
+	*d = loaded
+	return nil
+}
+
+// WriteCSV saves the boundaries as a few rows of "key,value" details followed by a
+// "decile,upper" table, which reads well in a spreadsheet
+func (d NTiles) WriteCSV(w io.Writer) error {
+	s := d.saved()
+	estimator, err := s.Estimator.MarshalText()
+	if err != nil {
+		return err
+	}
+	policy, err := s.OutOfRange.MarshalText()
+	if err != nil {
+		return err
+	}
+	var min string
+	if s.Min != nil {
+		min = strconv.FormatFloat(*s.Min, 'g', -1, 64)
+	}
+
+	probs := []string{"probabilities"}
+	for _, p := range s.Probabilities {
+		probs = append(probs, strconv.FormatFloat(p, 'g', -1, 64))
+	}
+	rows := [][]string{
+		{"estimator", string(estimator)},
+		{"fingerprint", s.Fingerprint},
+		{"fittedAt", s.FittedAt.Format(time.RFC3339Nano)},
+		{"outOfRange", string(policy)},
+		{"min", min},
+		{"deduplicated", strconv.FormatBool(s.Deduplicated)},
+		probs,
+		{"decile", "upper"},
+	}
+	for _, b := range s.Boundaries {
+		rows = append(rows, []string{strconv.Itoa(int(b.Decile)), strconv.FormatFloat(b.Upper, 'g', -1, 64)})
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+// ReadCSV restores boundaries saved by WriteCSV, checking they are still valid
+func ReadCSV(r io.Reader) (NTiles, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	rows, err := in.ReadAll()
+	if err != nil {
+		return NTiles{}, err
+	}
+
+	var s savedNTiles
+	inTable := false
+	for _, row := range rows {
+		if inTable {
+			if len(row) != 2 {
+				return NTiles{}, fmt.Errorf("%w: boundary row %v", CorruptNTilesError, row)
+			}
+			decile, err := strconv.ParseInt(row[0], 10, 8)
+			if err != nil {
+				return NTiles{}, err
+			}
+			upper, err := strconv.ParseFloat(row[1], 64)
+			if err != nil {
+				return NTiles{}, err
+			}
+			s.Boundaries = append(s.Boundaries, savedBoundary{Decile: int8(decile), Upper: upper})
+			continue
+		}
+
+		var value string
+		if len(row) > 1 {
+			value = row[1]
+		}
+		switch row[0] {
+		case "estimator":
+			err = s.Estimator.UnmarshalText([]byte(value))
+		case "fingerprint":
+			s.Fingerprint = value
+		case "fittedAt":
+			s.FittedAt, err = time.Parse(time.RFC3339Nano, value)
+		case "outOfRange":
+			err = s.OutOfRange.UnmarshalText([]byte(value))
+		case "min":
+			if value != "" {
+				var min float64
+				min, err = strconv.ParseFloat(value, 64)
+				s.Min = &min
+			}
+		case "deduplicated":
+			s.Deduplicated, err = strconv.ParseBool(value)
+		case "probabilities":
+			s.Probabilities = make([]float64, len(row)-1)
+			for i, p := range row[1:] {
+				if s.Probabilities[i], err = strconv.ParseFloat(p, 64); err != nil {
+					break
+				}
+			}
+		case "decile":
+			inTable = true
+		}
+		if err != nil {
+			return NTiles{}, err
+		}
+	}
+
+	return s.load()
+}
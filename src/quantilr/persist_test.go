@@ -0,0 +1,173 @@
+package quantilr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+var fittedAt time.Time = time.Date(2021, 3, 31, 12, 0, 0, 0, time.UTC)
+
+func fitForSaving(t *testing.T, deduplicate bool) NTiles {
+	t.Helper()
+	timeNow = func() time.Time { return fittedAt }
+	defer func() { timeNow = time.Now }()
+
+	d, err := Fit(c, Config{N: 10, Estimator: HyndmanFan7, Deduplicate: deduplicate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func checkReloaded(t *testing.T, want, got NTiles) {
+	t.Helper()
+	if got.Estimator != want.Estimator || got.Fingerprint != want.Fingerprint || !got.FittedAt.Equal(want.FittedAt) {
+		t.Errorf("For the fit details, wanted %v %s %v, but got %v %s %v", want.Estimator, want.Fingerprint, want.FittedAt, got.Estimator, got.Fingerprint, got.FittedAt)
+	}
+	if got.OutOfRange != want.OutOfRange {
+		t.Errorf("For the out-of-range policy, wanted %v, but got %v", want.OutOfRange, got.OutOfRange)
+	}
+	wantMin, wantKnown := want.Min()
+	gotMin, gotKnown := got.Min()
+	if gotMin != wantMin || gotKnown != wantKnown {
+		t.Errorf("For Min, wanted %v (%v), but got %v (%v)", wantMin, wantKnown, gotMin, gotKnown)
+	}
+	if len(got.Probabilities) != len(want.Probabilities) {
+		t.Fatalf("For the probabilities, wanted %v, but got %v", want.Probabilities, got.Probabilities)
+	}
+	for i := range want.Probabilities {
+		if got.Probabilities[i] != want.Probabilities[i] {
+			t.Errorf("For probability %d, wanted %v, but got %v", i, want.Probabilities[i], got.Probabilities[i])
+		}
+	}
+	if len(got.Pairs) != len(want.Pairs) {
+		t.Fatalf("For the pairs, wanted %v, but got %v", want.Pairs, got.Pairs)
+	}
+	for i := range want.Pairs {
+		if got.Pairs[i] != want.Pairs[i] {
+			t.Errorf("For pair %d, wanted %v, but got %v", i, want.Pairs[i], got.Pairs[i])
+		}
+	}
+	if !got.isSorted || got.isDeduped != want.isDeduped {
+		t.Errorf("For the invariants, wanted sorted and deduped %v, but got %v and %v", want.isDeduped, got.isSorted, got.isDeduped)
+	}
+	for _, value := range []float64{0.5, 1, 1.5, 4.2, 12, 13} {
+		wantDecile, wantErr := want.LookupValue(value)
+		gotDecile, gotErr := got.LookupValue(value)
+		if gotDecile != wantDecile || gotErr != wantErr {
+			t.Errorf("For LookupValue(%v), wanted %d (%v), but got %d (%v)", value, wantDecile, wantErr, gotDecile, gotErr)
+		}
+	}
+}
+
+func TestFitRecordsFingerprintAndDate(t *testing.T) {
+	d := fitForSaving(t, true)
+	if !d.FittedAt.Equal(fittedAt) {
+		t.Errorf("For FittedAt, wanted %v, but got %v", fittedAt, d.FittedAt)
+	}
+	if !strings.HasPrefix(d.Fingerprint, "sha256:") {
+		t.Errorf("For Fingerprint, wanted a sha256 digest, but got %s", d.Fingerprint)
+	}
+
+	var reversed countr.Counter
+	for i := len(c) - 1; i >= 0; i-- {
+		reversed = append(reversed, c[i])
+	}
+	if got := Fingerprint(reversed); got != d.Fingerprint {
+		t.Errorf("For the same values in another order, wanted %s, but got %s", d.Fingerprint, got)
+	}
+	if reversed[0] != c[len(c)-1] {
+		t.Errorf("For Fingerprint, wanted the pairs left in their order, but got %v first", reversed[0])
+	}
+
+	changed := append(countr.Counter(nil), c...)
+	changed[0].Count++
+	if got := Fingerprint(changed); got == d.Fingerprint {
+		t.Errorf("For different counts, wanted a different fingerprint to %s", got)
+	}
+}
+
+func TestNTilesJSONRoundTrip(t *testing.T) {
+	for _, deduplicate := range []bool{true, false} {
+		want := fitForSaving(t, deduplicate)
+		want.Sort()
+		want.OutOfRange = SentinelOutOfRange
+
+		b, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got NTiles
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		checkReloaded(t, want, got)
+
+		if !bytes.Contains(b, []byte(`"estimator":"HyndmanFan7"`)) {
+			t.Errorf("For the saved estimator, wanted its name, but got %s", b)
+		}
+	}
+}
+
+func TestNTilesCSVRoundTrip(t *testing.T) {
+	for _, deduplicate := range []bool{true, false} {
+		want := fitForSaving(t, deduplicate)
+		want.Sort()
+		want.OutOfRange = ClampToRange
+
+		var buf bytes.Buffer
+		if err := want.WriteCSV(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadCSV(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReloaded(t, want, got)
+	}
+}
+
+func TestLoadRejectsBrokenInvariants(t *testing.T) {
+	type testCase struct {
+		name  string
+		saved string
+		want  error
+	}
+	var testCases []testCase = []testCase{
+		{"no boundaries", `{"estimator":"Empirical","boundaries":[]}`, CorruptNTilesError},
+		{"unknown estimator", `{"estimator":"Median","boundaries":[{"decile":1,"upper":1}]}`, UnknownEstimatorError},
+		{"probability above one", `{"estimator":"Empirical","probabilities":[0.5,1.5],"boundaries":[{"decile":1,"upper":1}]}`, CorruptNTilesError},
+		{"decreasing probabilities", `{"estimator":"Empirical","probabilities":[0.6,0.4],"boundaries":[{"decile":1,"upper":1}]}`, CorruptNTilesError},
+		{"label out of range", `{"estimator":"Empirical","probabilities":[0.5,1],"boundaries":[{"decile":3,"upper":1}]}`, CorruptNTilesError},
+		{"repeated label", `{"estimator":"Empirical","boundaries":[{"decile":1,"upper":1},{"decile":1,"upper":2}]}`, CorruptNTilesError},
+		{"decreasing", `{"estimator":"Empirical","boundaries":[{"decile":1,"upper":2},{"decile":2,"upper":1}]}`, CorruptNTilesError},
+		{"unknown policy", `{"estimator":"Empirical","outOfRange":"wrap","boundaries":[{"decile":1,"upper":1}]}`, UnknownRangePolicyError},
+		{"min above the boundaries", `{"estimator":"Empirical","min":2,"boundaries":[{"decile":1,"upper":1}]}`, CorruptNTilesError},
+		{"duplicates claimed deduplicated", `{"estimator":"Empirical","deduplicated":true,"boundaries":[{"decile":1,"upper":1},{"decile":2,"upper":1}]}`, CorruptNTilesError},
+	}
+
+	for _, tc := range testCases {
+		var d NTiles
+		if err := json.Unmarshal([]byte(tc.saved), &d); !errors.Is(err, tc.want) {
+			t.Errorf("For %s, wanted %v, but got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestLoadSortsBoundaries(t *testing.T) {
+	saved := `{"estimator":"Empirical","probabilities":[0.5,1],"boundaries":[{"decile":2,"upper":5},{"decile":1,"upper":1}]}`
+	var d NTiles
+	if err := json.Unmarshal([]byte(saved), &d); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.LookupValue(3)
+	if got != 2 || err != nil {
+		t.Errorf("For LookupValue(3), wanted 2 with no warning, but got %d (%v)", got, err)
+	}
+}
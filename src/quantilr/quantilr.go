@@ -2,9 +2,9 @@ package quantilr
 
 import (
 	"errors"
+	"math"
 	"sort"
-
-	"gonum.org/v1/gonum/stat"
+	"time"
 )
 
 var (
@@ -13,8 +13,13 @@ var (
 	ValueNotFound              error = errors.New("the value provided does not appear in the calculated decile range")
 	WarnDecilesNotDeduplicated error = errors.New("The decile pairs provided were not deduplicated - this was performed automatically and then resorted")
 	WarnDecilesNotSorted       error = errors.New("The decile pairs provided were not deduplicated - this was performed automatically")
+	InvalidNError              error = errors.New("the number of n-tiles must be between 1 and 127")
+	NoDataError                error = errors.New("n-tiles cannot be calculated without any values")
 )
 
+// MaxN is the largest number of n-tiles whose labels fit in a DecilePair
+const MaxN int = 127
+
 type CountedPairs interface {
 	GetValues() []float64
 	GetCounts() []float64
@@ -23,42 +28,146 @@ type CountedPairs interface {
 	Swap(i, j int)
 }
 
+// counted holds values and their counts in parallel slices, e.g. one bootstrap resample
+type counted struct {
+	values []float64
+	counts []float64
+}
+
+func (c counted) GetValues() []float64 { return c.values }
+func (c counted) GetCounts() []float64 { return c.counts }
+func (c counted) Len() int             { return len(c.values) }
+func (c counted) Less(i, j int) bool   { return c.values[i] < c.values[j] }
+func (c counted) Swap(i, j int) {
+	c.values[i], c.values[j] = c.values[j], c.values[i]
+	c.counts[i], c.counts[j] = c.counts[j], c.counts[i]
+}
+
+// sortedCopy copies the values and counts of c in increasing order of value, leaving c as it was
+func sortedCopy(c CountedPairs) counted {
+	sorted := counted{
+		values: append([]float64(nil), c.GetValues()...),
+		counts: append([]float64(nil), c.GetCounts()...),
+	}
+	sort.Sort(sorted)
+	return sorted
+}
+
+// DecilePair holds the label of an n-tile (1 for the lowest) and the value at its upper boundary
 type DecilePair struct {
 	Decile int8
 	Weight float64
 }
-type Deciles struct {
-	Pairs     []DecilePair
-	isSorted  bool
-	isDeduped bool
+
+// NTiles splits a distribution into N equally probable buckets, labelled 1 through N.
+// Probabilities holds the cumulative probability at the upper boundary of each bucket.
+type NTiles struct {
+	Pairs         []DecilePair
+	Probabilities []float64
+	Estimator     Estimator   // how the boundaries were estimated
+	Fingerprint   string      // identifies the values the boundaries were fitted on (see Fingerprint)
+	FittedAt      time.Time   // when the boundaries were fitted
+	OutOfRange    RangePolicy // what lookups do with values outside the fitted range
+	min           float64
+	hasMin        bool
+	isSorted      bool
+	isDeduped     bool
 }
 
-func (d Deciles) Len() int           { return len(d.Pairs) }
-func (d Deciles) Less(i, j int) bool { return d.Pairs[i].Decile < d.Pairs[j].Decile }
-func (d Deciles) Swap(i, j int)      { d.Pairs[i], d.Pairs[j] = d.Pairs[j], d.Pairs[i] }
-func (d *Deciles) Sort() {
+// Min returns the smallest value the boundaries were fitted on. It is only known for fitted or
+// reloaded n-tiles.
+func (d NTiles) Min() (float64, bool) {
+	return d.min, d.hasMin
+}
+
+// Deciles are the N = 10 case of NTiles
+type Deciles = NTiles
+
+// N returns the number of n-tiles the boundaries were calculated for. Deduplication can leave
+// fewer pairs than this. When the probabilities are unknown, the highest label is used.
+func (d NTiles) N() int {
+	if len(d.Probabilities) > 0 {
+		return len(d.Probabilities)
+	}
+	var n int8
+	for _, p := range d.Pairs {
+		if p.Decile > n {
+			n = p.Decile
+		}
+	}
+	return int(n)
+}
+
+func (d NTiles) Len() int           { return len(d.Pairs) }
+func (d NTiles) Less(i, j int) bool { return d.Pairs[i].Decile < d.Pairs[j].Decile }
+func (d NTiles) Swap(i, j int)      { d.Pairs[i], d.Pairs[j] = d.Pairs[j], d.Pairs[i] }
+func (d *NTiles) Sort() {
 	if !d.isSorted {
 		sort.Sort(d)
 		d.isSorted = true
 	}
 }
 
-func NewDeciles(c CountedPairs, deduplicateDeciles bool) (Deciles, error) {
-	deciles, pointValues := Quantiles(c, []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1.0})
+// Probabilities returns the cumulative probabilities at the upper boundary of each of n n-tiles,
+// i.e. 1/n, 2/n, ... n/n. Each is calculated from integers, so the last is exactly 1.
+func Probabilities(n int) []float64 {
+	probs := make([]float64, n)
+	for i := 0; i < n; i++ {
+		probs[i] = float64(i+1) / float64(n)
+	}
+	return probs
+}
 
-	var d Deciles = Deciles{
-		Pairs:     make([]DecilePair, len(deciles)),
-		isSorted:  false,
-		isDeduped: false,
+// Config decides how n-tiles are fitted
+type Config struct {
+	N           int       // the number of n-tiles, 10 for deciles
+	Estimator   Estimator // how each boundary is estimated from the counted values
+	Deduplicate bool      // merge boundaries which fall on the same value
+}
+
+// Fit calculates the boundaries of cfg.N equally probable buckets over the counted values
+func Fit(c CountedPairs, cfg Config) (NTiles, error) {
+	if cfg.N < 1 || cfg.N > MaxN {
+		return NTiles{}, InvalidNError
+	}
+	if !cfg.Estimator.Valid() {
+		return NTiles{}, UnknownEstimatorError
+	}
+	if c.Len() == 0 {
+		return NTiles{}, NoDataError
 	}
+	if cfg.Estimator.isHyndmanFan() && !wholeCounts(c.GetCounts()) {
+		return NTiles{}, FractionalCountsError
+	}
+
+	probs, pointValues := cfg.Estimator.Quantiles(c, Probabilities(cfg.N))
 
-	for i := 0; i < len(deciles); i++ {
-		thisDecile := int8(deciles[i] * 10)
+	var d NTiles = NTiles{
+		Pairs:         make([]DecilePair, len(probs)),
+		Probabilities: probs,
+		Estimator:     cfg.Estimator,
+		Fingerprint:   Fingerprint(c),
+		FittedAt:      timeNow().UTC(),
+		min:           math.Inf(1),
+		hasMin:        true,
+		isSorted:      false,
+		isDeduped:     false,
+	}
+
+	for _, value := range c.GetValues() {
+		if value < d.min {
+			d.min = value
+		}
+	}
+
+	for i := 0; i < len(probs); i++ {
+		// labels come from the index, not the probability, so they are always exact
+		thisDecile := int8(i + 1)
 		thisWeight := pointValues[i]
 		d.Pairs[i] = DecilePair{Decile: thisDecile, Weight: thisWeight}
 	}
 
-	if deduplicateDeciles {
+	if cfg.Deduplicate {
 		d.depulicateDeciles()
 	}
 
@@ -68,55 +177,53 @@ func NewDeciles(c CountedPairs, deduplicateDeciles bool) (Deciles, error) {
 	return d, nil
 }
 
-func (d *Deciles) depulicateDeciles() {
-	var deduped = make(map[float64]int8)
-	if !d.isSorted {
-		sort.Sort(d)
-		d.isSorted = true
+// chooseEstimator picks the optional estimator passed to NewNTiles, NewDeciles or Quantiles,
+// defaulting to Empirical. Only the first is used.
+func chooseEstimator(estimator []Estimator) Estimator {
+	if len(estimator) == 0 {
+		return Empirical
 	}
-	for i := 0; i < d.Len(); i++ {
-		thisPair := d.Pairs[i]
-		thisWeight := thisPair.Weight
-		thisDecile := thisPair.Decile
-
-		deduped[thisWeight] = thisDecile
-	}
-
-	var newDeciles []DecilePair = make([]DecilePair, len(deduped))
-	i := 0
-	for value, decile := range deduped {
-		newPair := DecilePair{Decile: decile, Weight: value}
-		newDeciles[i] = newPair
-		i++
-	}
-
-	d.Pairs = newDeciles
-	d.isSorted = false
+	return estimator[0]
 }
 
-func Quantiles(c CountedPairs, probs []float64) (quantiles, pointValues []float64) {
-	// return value instantiation
-	quantiles = make([]float64, len(probs))
-	pointValues = make([]float64, len(probs))
+// NewNTiles calculates the boundaries of n equally probable buckets over the counted values,
+// using the Empirical estimator unless another is given
+func NewNTiles(c CountedPairs, n int, deduplicateDeciles bool, estimator ...Estimator) (NTiles, error) {
+	return Fit(c, Config{N: n, Estimator: chooseEstimator(estimator), Deduplicate: deduplicateDeciles})
+}
 
-	// we sort here to ensure that the values are in increasing order,
-	// which is required for calculating and collecting quantiles
-	// However, this is probably more a guard than a requirement, since
-	// the Countr package should sort the CountedPairs prior to return.
-	sort.Sort(c)
+// NewDeciles calculates the boundaries of the ten deciles over the counted values, using the
+// Empirical estimator unless another is given
+func NewDeciles(c CountedPairs, deduplicateDeciles bool, estimator ...Estimator) (Deciles, error) {
+	return NewNTiles(c, 10, deduplicateDeciles, estimator...)
+}
 
-	values := c.GetValues()
-	weights := c.GetCounts()
+// depulicateDeciles merges boundaries which fall on the same value into the highest n-tile
+// sharing it, leaving the pairs sorted
+func (d *NTiles) depulicateDeciles() {
+	d.Sort()
 
-	for i, p := range probs {
-		quantiles[i] = p
-		pointValues[i] = stat.Quantile(p, stat.Empirical, values, weights)
+	deduped := make([]DecilePair, 0, d.Len())
+	for _, p := range d.Pairs {
+		last := len(deduped) - 1
+		if last >= 0 && deduped[last].Weight == p.Weight {
+			deduped[last].Decile = p.Decile
+			continue
+		}
+		deduped = append(deduped, p)
 	}
 
-	return quantiles, pointValues
+	d.Pairs = deduped
+	d.isDeduped = true
 }
 
-func (d Deciles) ScaleToOne() error {
+// Quantiles returns the value at each of the cumulative probabilities probs, using the
+// Empirical estimator unless another is given (see Estimator.Quantiles)
+func Quantiles(c CountedPairs, probs []float64, estimator ...Estimator) (quantiles, pointValues []float64) {
+	return chooseEstimator(estimator).Quantiles(c, probs)
+}
+
+func (d NTiles) ScaleToOne() error {
 	var denominator float64
 	for i := 0; i < d.Len(); i++ {
 		e := &d.Pairs[i]
@@ -148,25 +255,24 @@ func (d Deciles) ScaleToOne() error {
 	return nil
 }
 
-func (d Deciles) LookupValue(v float64) (decileOfValue int8, errorWarning error) {
-	errorWarning = nil
-	var sortWarningSafeToApply bool = true // needed so that the sorting done after deduping (if performed) is not overwritten
-	if !d.isDeduped {
-		d.depulicateDeciles()
+// LookupValue returns the n-tile v falls into. It compiles a Table on every call and warns when
+// the boundaries were not deduplicated or sorted beforehand; compile once with Compile instead
+// when looking up many values.
+func (d NTiles) LookupValue(v float64) (decileOfValue int8, errorWarning error) {
+	switch {
+	case !d.isDeduped:
 		errorWarning = WarnDecilesNotDeduplicated
-		sortWarningSafeToApply = false
+	case !d.isSorted:
+		errorWarning = WarnDecilesNotSorted
 	}
-	if !d.isSorted {
-		d.Sort()
-		if sortWarningSafeToApply {
-			errorWarning = WarnDecilesNotSorted
-		}
+
+	t, err := d.Compile()
+	if err != nil {
+		return -1, err
 	}
-	for i := 0; i < d.Len(); i++ {
-		if v <= d.Pairs[i].Weight {
-			return d.Pairs[i].Decile, errorWarning
-		}
+	decileOfValue, err = t.Lookup(v)
+	if err != nil {
+		return -1, err
 	}
-
-	return -1, ValueNotFound
+	return decileOfValue, errorWarning
 }
@@ -252,3 +252,110 @@ func TestLookupValueSortsAutomatically(t *testing.T) {
 		t.Errorf("When sending a non-deduplicated set of DecilePairs, should have returned a WarnDecilesNotSorted error, but got %s", err)
 	}
 }
+
+func TestProbabilitiesAreExact(t *testing.T) {
+	for _, n := range []int{3, 4, 5, 7, 10, 100} {
+		got := Probabilities(n)
+		if len(got) != n {
+			t.Errorf("For n = %d, wanted %d probabilities, but got %d", n, n, len(got))
+			continue
+		}
+		if got[n-1] != 1 {
+			t.Errorf("For n = %d, the last probability should be exactly 1, but got %.20f", n, got[n-1])
+		}
+	}
+}
+
+func TestNewNTilesQuartiles(t *testing.T) {
+	want := []DecilePair{
+		{Decile: 1, Weight: 1},
+		{Decile: 2, Weight: 2},
+		{Decile: 3, Weight: 7},
+		{Decile: 4, Weight: 12},
+	}
+
+	got, err := NewNTiles(c, 4, false)
+	if err != nil {
+		t.Fatalf("NewNTiles errored unexpectedly with %s", err)
+	}
+	if got.N() != 4 {
+		t.Errorf("Quartiles should have N of 4, but got %d", got.N())
+	}
+	if got.Len() != len(want) {
+		t.Fatalf("Wanted %d quartiles, but got %d", len(want), got.Len())
+	}
+	for i, g := range got.Pairs {
+		if g != want[i] {
+			t.Errorf("For index %d, wanted %v, but got %v", i, want[i], g)
+		}
+	}
+}
+
+func TestNewNTilesPercentileLabels(t *testing.T) {
+	got, err := NewNTiles(c, 100, false)
+	if err != nil {
+		t.Fatalf("NewNTiles errored unexpectedly with %s", err)
+	}
+	for i, g := range got.Pairs {
+		if g.Decile != int8(i+1) {
+			t.Errorf("For index %d, wanted percentile %d, but got %d", i, i+1, g.Decile)
+		}
+	}
+}
+
+func TestNewNTilesRejectsBadInput(t *testing.T) {
+	for _, n := range []int{0, -1, MaxN + 1} {
+		if _, err := NewNTiles(c, n, false); err != InvalidNError {
+			t.Errorf("For n = %d, wanted an InvalidNError, but got %v", n, err)
+		}
+	}
+	if _, err := NewNTiles(countr.Counter{}, 10, false); err != NoDataError {
+		t.Errorf("For an empty counter, wanted a NoDataError, but got %v", err)
+	}
+}
+
+func TestNWithoutProbabilitiesUsesHighestLabel(t *testing.T) {
+	var d = Deciles{
+		Pairs: []DecilePair{
+			{Decile: 3, Weight: 1.0},
+			{Decile: 10, Weight: 12.0},
+		},
+	}
+	if d.N() != 10 {
+		t.Errorf("N should have returned 10, but got %d", d.N())
+	}
+}
+
+func TestNewDecilesFromSketch(t *testing.T) {
+	s, err := countr.NewSketch(0.01, 123456)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 100000
+	for i := 0; i < n; i++ {
+		// a stream in an awkward order: evens rising, then odds falling
+		if i < n/2 {
+			s.Add(float64(2 * i))
+		} else {
+			s.Add(float64(2*(n-i) - 1))
+		}
+	}
+
+	got, err := NewDeciles(s.Counter(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != 10 {
+		t.Fatalf("Wanted 10 deciles from the sketch, but got %d", got.Len())
+	}
+	for i, pair := range got.Pairs {
+		// the stream holds each of 0 to n-1 once, so decile d ends near d/10 of the way up
+		want := float64(i+1) / 10 * n
+		if diff := pair.Weight - want; diff > s.Epsilon()*n || diff < -s.Epsilon()*n {
+			t.Errorf("For decile %d, wanted a boundary within %3.0f of %3.0f, but got %3.0f", pair.Decile, s.Epsilon()*n, want, pair.Weight)
+		}
+	}
+	if v, _ := got.LookupValue(s.Max()); v != 10 {
+		t.Errorf("The maximum should fall in decile 10, but fell in %d", v)
+	}
+}
@@ -0,0 +1,104 @@
+package quantilr
+
+import (
+	"sort"
+
+	"golang.org/x/exp/rand"
+)
+
+// TiePolicy decides the ranks given to equal values
+type TiePolicy int
+
+const (
+	// AverageTies gives tied values the mean of the ranks they span, as R's rank does by default
+	AverageTies TiePolicy = iota
+	// MinTies gives tied values the lowest of the ranks they span
+	MinTies
+	// DenseTies gives tied values the same rank, leaving no gap before the next value, so that
+	// ranks count distinct values
+	DenseTies
+	// RandomTies breaks ties in a random (seeded) order, so every value has a rank of its own
+	// and buckets are as equally populated as possible
+	RandomTies
+)
+
+// RankConfig decides how values are bucketed by rank
+type RankConfig struct {
+	N    int       // the number of buckets, 10 for deciles
+	Ties TiePolicy // how equal values are ranked
+	Seed uint64    // the seed for RandomTies
+}
+
+// Ranks returns the 1-based rank of each value, in the order the values were given
+func Ranks(values []float64, ties TiePolicy, seed uint64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	var rng *rand.Rand
+	if ties == RandomTies {
+		rng = rand.New(rand.NewSource(seed))
+	}
+
+	ranks := make([]float64, len(values))
+	var distinct float64
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+		distinct++
+
+		// positions start+1 to end are shared by the tied values
+		block := order[start:end]
+		if rng != nil {
+			rng.Shuffle(len(block), func(a, b int) { block[a], block[b] = block[b], block[a] })
+		}
+		for k, i := range block {
+			switch ties {
+			case MinTies:
+				ranks[i] = float64(start + 1)
+			case DenseTies:
+				ranks[i] = distinct
+			case RandomTies:
+				ranks[i] = float64(start + k + 1)
+			default:
+				ranks[i] = float64(start+1+end) / 2
+			}
+		}
+		start = end
+	}
+	return ranks
+}
+
+// AssignByRank puts each value into one of cfg.N buckets by its rank rather than by value
+// boundaries, so that buckets are (as near as possible) equally populated: the bucket of a value
+// ranked r of m is floor(N * (r - 1) / m) + 1, with m the number of distinct ranks for DenseTies.
+// With RandomTies, any dataset of at least N values fills every bucket.
+func AssignByRank(values []float64, cfg RankConfig) ([]int8, error) {
+	if cfg.N < 1 || cfg.N > MaxN {
+		return nil, InvalidNError
+	}
+	if len(values) == 0 {
+		return nil, NoDataError
+	}
+
+	ranks := Ranks(values, cfg.Ties, cfg.Seed)
+	m := float64(len(values))
+	if cfg.Ties == DenseTies {
+		m = 0
+		for _, r := range ranks {
+			if r > m {
+				m = r
+			}
+		}
+	}
+
+	buckets := make([]int8, len(values))
+	for i, r := range ranks {
+		buckets[i] = int8(float64(cfg.N)*(r-1)/m) + 1
+	}
+	return buckets, nil
+}
@@ -0,0 +1,99 @@
+package quantilr
+
+import (
+	"testing"
+)
+
+func TestRanksTiePolicies(t *testing.T) {
+	// as R's rank(x, ties.method = ...) for "average" and "min", and dplyr's dense_rank
+	x := []float64{3, 1, 3, 2, 3, 5}
+
+	type testCase struct {
+		Ties TiePolicy
+		Want []float64
+	}
+	var testCases []testCase = []testCase{
+		{AverageTies, []float64{4, 1, 4, 2, 4, 6}},
+		{MinTies, []float64{3, 1, 3, 2, 3, 6}},
+		{DenseTies, []float64{3, 1, 3, 2, 3, 4}},
+	}
+
+	for _, tc := range testCases {
+		got := Ranks(x, tc.Ties, 0)
+		for i, w := range tc.Want {
+			if got[i] != w {
+				t.Errorf("For tie policy %d, wanted ranks %v, but got %v", tc.Ties, tc.Want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestRandomTiesGivesEveryValueItsOwnRank(t *testing.T) {
+	got := Ranks(v, RandomTies, 42)
+	seen := make(map[float64]bool)
+	for i, r := range got {
+		if seen[r] {
+			t.Errorf("Rank %f was given to more than one value", r)
+		}
+		seen[r] = true
+		// the seven 1s take the first seven ranks between them
+		if v[i] == 1 && r > 7 {
+			t.Errorf("A tied 1 should rank within the first seven, but ranked %f", r)
+		}
+	}
+
+	again := Ranks(v, RandomTies, 42)
+	for i := range got {
+		if got[i] != again[i] {
+			t.Fatalf("The same seed should give the same ranks, but got %v and %v", got, again)
+		}
+	}
+}
+
+func TestAssignByRankFillsEveryDecile(t *testing.T) {
+	// value boundaries only find 8 distinct deciles in v (see TestNewDecilesDeduplicatesPairsWithSameValue)
+	buckets, err := AssignByRank(v, RankConfig{N: 10, Ties: RandomTies, Seed: 123456})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[int8]int)
+	for _, b := range buckets {
+		counts[b]++
+	}
+	for d := int8(1); d <= 10; d++ {
+		// 21 values over 10 deciles
+		if counts[d] < 2 || counts[d] > 3 {
+			t.Errorf("Decile %d should hold 2 or 3 of the 21 values, but holds %d", d, counts[d])
+		}
+	}
+}
+
+func TestAssignByRankDense(t *testing.T) {
+	buckets, err := AssignByRank(v, RankConfig{N: 10, Ties: DenseTies})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int8]bool)
+	for i, b := range buckets {
+		seen[b] = true
+		if v[i] == v[0] && b != buckets[0] {
+			t.Errorf("Tied values should share a decile under DenseTies, but got %d and %d", buckets[0], b)
+		}
+	}
+	// 13 distinct values are enough for all ten deciles
+	if len(seen) != 10 {
+		t.Errorf("DenseTies should have filled all 10 deciles, but filled %d", len(seen))
+	}
+}
+
+func TestAssignByRankRejectsBadInput(t *testing.T) {
+	if _, err := AssignByRank(v, RankConfig{N: 0}); err != InvalidNError {
+		t.Errorf("For N = 0, wanted an InvalidNError, but got %v", err)
+	}
+	if _, err := AssignByRank(nil, RankConfig{N: 10}); err != NoDataError {
+		t.Errorf("For no values, wanted a NoDataError, but got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package quantilr
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var (
+	UnorderedBoundariesError error = errors.New("the upper boundaries must not decrease from one n-tile to the next")
+	UnknownRangePolicyError  error = errors.New("the out-of-range policy is not one of ErrorOutOfRange, ClampToRange or SentinelOutOfRange")
+)
+
+// The buckets SentinelOutOfRange assigns. Both are negative, so like an unset bucket (0) they
+// are never counted as an n-tile.
+const (
+	BelowRange int8 = -1
+	AboveRange int8 = -2
+)
+
+// RangePolicy decides what a lookup does with a value outside the range the boundaries were
+// fitted on: above the highest boundary, or below the smallest fitted value (when it is known).
+// The default, ErrorOutOfRange, only errors above the range: below it, as quantilr always has,
+// anything at or below the first boundary gets the lowest n-tile.
+type RangePolicy int
+
+const (
+	// ErrorOutOfRange returns ValueNotFound above the highest boundary, and assigns the lowest
+	// n-tile below the range
+	ErrorOutOfRange RangePolicy = iota
+	// ClampToRange assigns the lowest or highest n-tile
+	ClampToRange
+	// SentinelOutOfRange assigns BelowRange or AboveRange
+	SentinelOutOfRange
+)
+
+func (p RangePolicy) String() string {
+	switch p {
+	case ErrorOutOfRange:
+		return "error"
+	case ClampToRange:
+		return "clamp"
+	case SentinelOutOfRange:
+		return "sentinel"
+	default:
+		return "RangePolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// Valid reports whether p is one of the policies above
+func (p RangePolicy) Valid() bool {
+	return p >= ErrorOutOfRange && p <= SentinelOutOfRange
+}
+
+// MarshalText writes the policy's name, as String does
+func (p RangePolicy) MarshalText() ([]byte, error) {
+	if !p.Valid() {
+		return nil, UnknownRangePolicyError
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText reads a policy's name, as String writes it
+func (p *RangePolicy) UnmarshalText(text []byte) error {
+	for candidate := ErrorOutOfRange; candidate <= SentinelOutOfRange; candidate++ {
+		if candidate.String() == string(text) {
+			*p = candidate
+			return nil
+		}
+	}
+	return UnknownRangePolicyError
+}
+
+// Table is a compiled, read-only copy of n-tile boundaries. It is built once, after which lookups
+// are a binary search and never modify it, so a Table is safe for concurrent use.
+type Table struct {
+	uppers []float64 // the distinct upper boundaries, ascending
+	labels []int8    // the n-tile each boundary closes
+	n      int
+	min    float64 // the smallest fitted value, or -Inf when it is not known
+	policy RangePolicy
+}
+
+// Compile builds a Table from the boundaries. Boundaries which fall on the same value are merged
+// into the highest n-tile sharing it, as deduplication does, however the pairs are ordered.
+func (d NTiles) Compile() (*Table, error) {
+	if len(d.Pairs) == 0 {
+		return nil, NoDataError
+	}
+	if !d.OutOfRange.Valid() {
+		return nil, UnknownRangePolicyError
+	}
+
+	pairs := make([]DecilePair, len(d.Pairs))
+	copy(pairs, d.Pairs)
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Decile < pairs[j].Decile })
+
+	t := &Table{
+		uppers: make([]float64, 0, len(pairs)),
+		labels: make([]int8, 0, len(pairs)),
+		n:      d.N(),
+		min:    math.Inf(-1),
+		policy: d.OutOfRange,
+	}
+	if min, ok := d.Min(); ok {
+		t.min = min
+	}
+	for _, p := range pairs {
+		if math.IsNaN(p.Weight) {
+			return nil, UnorderedBoundariesError
+		}
+		last := len(t.uppers) - 1
+		switch {
+		case last < 0 || p.Weight > t.uppers[last]:
+			t.uppers = append(t.uppers, p.Weight)
+			t.labels = append(t.labels, p.Decile)
+		case p.Weight == t.uppers[last]:
+			t.labels[last] = p.Decile
+		default:
+			return nil, UnorderedBoundariesError
+		}
+	}
+	return t, nil
+}
+
+// N returns the number of n-tiles the boundaries were calculated for
+func (t *Table) N() int { return t.n }
+
+// Len returns the number of distinct boundaries
+func (t *Table) Len() int { return len(t.uppers) }
+
+// Policy returns what Lookup does with values outside the fitted range
+func (t *Table) Policy() RangePolicy { return t.policy }
+
+// Highest returns the highest upper boundary, above which values are out of range
+func (t *Table) Highest() float64 { return t.uppers[len(t.uppers)-1] }
+
+// Min returns the smallest value the boundaries were fitted on, below which values are out of
+// range, or -Inf when it is not known
+func (t *Table) Min() float64 { return t.min }
+
+// Pairs returns a copy of the distinct boundaries, in ascending order
+func (t *Table) Pairs() []DecilePair {
+	pairs := make([]DecilePair, len(t.uppers))
+	for i := range t.uppers {
+		pairs[i] = DecilePair{Decile: t.labels[i], Weight: t.uppers[i]}
+	}
+	return pairs
+}
+
+// Lookup returns the n-tile v falls into: the first whose upper boundary is at least v. Values
+// above the highest boundary, and with ClampToRange or SentinelOutOfRange values below the
+// smallest fitted value, are handled as the RangePolicy says. NaN is always ValueNotFound.
+func (t *Table) Lookup(v float64) (int8, error) {
+	if math.IsNaN(v) {
+		return -1, ValueNotFound
+	}
+
+	i := sort.SearchFloat64s(t.uppers, v)
+	switch {
+	case v < t.min && t.policy != ErrorOutOfRange:
+		return t.outOfRange(BelowRange, t.labels[0])
+	case i == len(t.uppers):
+		return t.outOfRange(AboveRange, t.labels[len(t.labels)-1])
+	}
+	return t.labels[i], nil
+}
+
+func (t *Table) outOfRange(sentinel, clamped int8) (int8, error) {
+	switch t.policy {
+	case ClampToRange:
+		return clamped, nil
+	case SentinelOutOfRange:
+		return sentinel, nil
+	default:
+		return -1, ValueNotFound
+	}
+}
@@ -0,0 +1,175 @@
+package quantilr
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+func TestTableLookupMatchesLookupValue(t *testing.T) {
+	d, err := NewDeciles(c, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := d.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []float64{-5, 1, 1.2, 1.5, 2, 3.5, 6, 11.9, 12} {
+		want, wantErr := d.LookupValue(value)
+		got, err := table.Lookup(value)
+		if got != want || err != wantErr {
+			t.Errorf("For %v, wanted %d (%v), but got %d (%v)", value, want, wantErr, got, err)
+		}
+	}
+}
+
+func TestTableLookupRejectsValuesAboveTheTable(t *testing.T) {
+	d, _ := NewDeciles(c, true)
+	table, _ := d.Compile()
+	for _, value := range []float64{12.0001, math.Inf(1), math.NaN()} {
+		if _, err := table.Lookup(value); err != ValueNotFound {
+			t.Errorf("For %v, wanted %v, but got %v", value, ValueNotFound, err)
+		}
+	}
+}
+
+func TestCompileMergesDuplicatesIntoHighestLabel(t *testing.T) {
+	d := NTiles{Pairs: []DecilePair{
+		{Decile: 3, Weight: 1},
+		{Decile: 4, Weight: 2},
+		{Decile: 1, Weight: 1},
+		{Decile: 2, Weight: 1},
+	}}
+	table, err := d.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DecilePair{{Decile: 3, Weight: 1}, {Decile: 4, Weight: 2}}
+	got := table.Pairs()
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("For the compiled pairs, wanted %v, but got %v", want, got)
+	}
+	if table.N() != 4 {
+		t.Errorf("For N, wanted 4, but got %d", table.N())
+	}
+	if d.Pairs[0].Decile != 3 {
+		t.Errorf("Compile should not have reordered the NTiles' own pairs, but got %v", d.Pairs)
+	}
+}
+
+func TestCompileRejectsBadBoundaries(t *testing.T) {
+	type testCase struct {
+		name  string
+		pairs []DecilePair
+		want  error
+	}
+	var testCases []testCase = []testCase{
+		{"no pairs", nil, NoDataError},
+		{"decreasing", []DecilePair{{Decile: 1, Weight: 2}, {Decile: 2, Weight: 1}}, UnorderedBoundariesError},
+		{"NaN", []DecilePair{{Decile: 1, Weight: math.NaN()}}, UnorderedBoundariesError},
+	}
+	for _, tc := range testCases {
+		if _, err := (NTiles{Pairs: tc.pairs}).Compile(); !errors.Is(err, tc.want) {
+			t.Errorf("For %s, wanted %v, but got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestLookupValueLeavesPairsAlone(t *testing.T) {
+	d := NTiles{Pairs: []DecilePair{{Decile: 2, Weight: 5}, {Decile: 1, Weight: 1}, {Decile: 3, Weight: 5}}}
+	if _, err := d.LookupValue(3); err != WarnDecilesNotDeduplicated {
+		t.Errorf("For the warning, wanted %v, but got %v", WarnDecilesNotDeduplicated, err)
+	}
+	if len(d.Pairs) != 3 || d.Pairs[0].Decile != 2 {
+		t.Errorf("LookupValue should not have modified the pairs, but got %v", d.Pairs)
+	}
+}
+
+func TestTableIsSafeForConcurrentUse(t *testing.T) {
+	d, _ := NewDeciles(c, true)
+	table, _ := d.Compile()
+	want, _ := table.Lookup(6)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if got, err := table.Lookup(6); got != want || err != nil {
+					t.Errorf("For a concurrent lookup, wanted %d, but got %d (%v)", want, got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLookupOutOfRangePolicies(t *testing.T) {
+	fitted, err := NewDeciles(c, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		policy  RangePolicy
+		value   float64
+		want    int8
+		wantErr error
+	}
+	var testCases []testCase = []testCase{
+		{ErrorOutOfRange, 0.5, 3, nil},
+		{ErrorOutOfRange, 13, -1, ValueNotFound},
+		{ErrorOutOfRange, 1, 3, nil},
+		{ClampToRange, 0.5, 3, nil},
+		{ClampToRange, 13, 10, nil},
+		{SentinelOutOfRange, 0.5, BelowRange, nil},
+		{SentinelOutOfRange, 13, AboveRange, nil},
+		{SentinelOutOfRange, 12, 10, nil},
+		{SentinelOutOfRange, math.NaN(), -1, ValueNotFound},
+	}
+
+	for _, tc := range testCases {
+		fitted.OutOfRange = tc.policy
+		table, err := fitted.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := table.Lookup(tc.value)
+		if got != tc.want || err != tc.wantErr {
+			t.Errorf("For %v under %v, wanted %d (%v), but got %d (%v)", tc.value, tc.policy, tc.want, tc.wantErr, got, err)
+		}
+	}
+}
+
+func TestLookupWithoutMinNeverFallsBelowRange(t *testing.T) {
+	d := NTiles{Pairs: []DecilePair{{Decile: 1, Weight: 0}, {Decile: 2, Weight: 10}}, OutOfRange: SentinelOutOfRange}
+	table, _ := d.Compile()
+	if got, err := table.Lookup(-100); got != 1 || err != nil {
+		t.Errorf("For a value below unfitted boundaries, wanted 1, but got %d (%v)", got, err)
+	}
+}
+
+func TestDefaultPolicyGivesValuesBelowTheFirstBoundaryTheLowestNTile(t *testing.T) {
+	d, err := NewDeciles(countr.Count([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := d.LookupValue(0.5); got != 1 || err != nil {
+		t.Errorf("For 0.5 under the default policy, wanted 1, but got %d (%v)", got, err)
+	}
+}
+
+func TestCompileRejectsUnknownRangePolicy(t *testing.T) {
+	d := NTiles{Pairs: []DecilePair{{Decile: 1, Weight: 0}}, OutOfRange: RangePolicy(7)}
+	if _, err := d.Compile(); err != UnknownRangePolicyError {
+		t.Errorf("For an unknown policy, wanted %v, but got %v", UnknownRangePolicyError, err)
+	}
+}
@@ -0,0 +1,147 @@
+package readr
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrorBudgetExceeded error = errors.New("too many rows were rejected")
+var MissingCellError error = errors.New("row has no cell for this column")
+
+// ParseError describes a row which could not be turned into a PriceRecord
+type ParseError struct {
+	File   string // empty when the Reader was not opened from a file
+	Line   int    // 1-based, counting header rows and one line per row
+	Field  string // the FieldIndexMap field being parsed, empty if the row itself was malformed
+	Column string // the header name of the field's column, or the field name without a header
+	Value  string // the raw contents of the cell
+	Err    error
+
+	index int // the column's index in the row
+}
+
+func (e *ParseError) Error() string {
+	where := "line " + strconv.Itoa(e.Line)
+	if e.File != "" {
+		where = e.File + ":" + strconv.Itoa(e.Line)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %v", where, e.Err)
+	}
+	return fmt.Sprintf("%s: column %s: cannot parse %q: %v", where, e.Column, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// cellError builds the ParseError for the field found at index of line
+func cellError(line []string, field string, index int, err error) *ParseError {
+	pe := &ParseError{Field: field, Column: field, Err: err, index: index}
+	if index >= 0 && index < len(line) {
+		pe.Value = line[index]
+	}
+	return pe
+}
+
+// checkWidth makes sure every column the fieldMap points to exists in line, and that only the
+// fields which can be built from the row index are given a negative index
+func checkWidth(line []string, fieldMap *FieldIndexMap) *ParseError {
+	for _, c := range fieldMap.columns() {
+		if c.index >= len(line) || (c.index < 0 && !c.optional) {
+			return cellError(line, c.field, c.index, MissingCellError)
+		}
+	}
+	return nil
+}
+
+type fieldColumn struct {
+	field    string
+	index    int
+	optional bool // built from the row index when index is negative
+}
+
+// columns lists the fields of the map, in the order they are declared
+func (m *FieldIndexMap) columns() []fieldColumn {
+	return []fieldColumn{
+		{"UUID", m.UUID, true},
+		{"ProductID", m.ProductID, false},
+		{"CustomerID", m.CustomerID, false},
+		{"PurchaseDate", m.PurchaseDate, false},
+		{"DocumentNumber", m.DocumentNumber, true},
+		{"DocumentLineNumber", m.DocumentLineNumber, true},
+		{"Price", m.Price, false},
+	}
+}
+
+// Rejections summarises the rows a lenient Reader skipped, so that whoever owns the extract
+// can fix it
+type Rejections struct {
+	File   string
+	Rows   int // data rows read, whether accepted or rejected
+	Errors []*ParseError
+}
+
+// ByColumn counts the rejected rows per column. Malformed rows are counted under "(row)".
+func (r *Rejections) ByColumn() map[string]int {
+	counts := make(map[string]int)
+	for _, e := range r.Errors {
+		counts[rejectedColumn(e)]++
+	}
+	return counts
+}
+
+func rejectedColumn(e *ParseError) string {
+	if e.Field == "" {
+		return "(row)"
+	}
+	return e.Column
+}
+
+// Summary describes the rejected rows per column, with the first offending line of each
+func (r *Rejections) Summary() string {
+	var b strings.Builder
+	source := r.File
+	if source == "" {
+		source = "input"
+	}
+	fmt.Fprintf(&b, "%d of %d rows rejected from %s\n", len(r.Errors), r.Rows, source)
+
+	counts := r.ByColumn()
+	columns := make([]string, 0, len(counts))
+	for c := range counts {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	for _, c := range columns {
+		for _, e := range r.Errors {
+			if rejectedColumn(e) == c {
+				fmt.Fprintf(&b, "  %s: %d (first at line %d, value %q: %v)\n", c, counts[c], e.Line, e.Value, e.Err)
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// WriteCSV writes one row per rejected row, with its line, column, raw value and error
+func (r *Rejections) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"file", "line", "column", "value", "error"}); err != nil {
+		return err
+	}
+	for _, e := range r.Errors {
+		row := []string{e.File, strconv.Itoa(e.Line), rejectedColumn(e), e.Value, e.Err.Error()}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
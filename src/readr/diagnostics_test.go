@@ -0,0 +1,167 @@
+package readr
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rawLines with a bad price on line 3, a bad date on line 5 and a short row on line 7
+var dirtyLines string = `productId,customerId,purchaseDate,totalPrice
+bed_bath_table:8,15df0,2017-02-28,101.14
+bed_bath_table:8,f4c13,2017-02-28,n/a
+bed_bath_table:9,0dc4b,2017-03-01,101.14
+bed_bath_table:8,d98e2,02/03/2017,104.7
+bed_bath_table:8,2ed85,2017-03-04,101.14
+bed_bath_table:8,2ed85
+`
+
+func TestNextReturnsParseError(t *testing.T) {
+	r, err := NewReader(strings.NewReader(dirtyLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Next()
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Next should have returned a *ParseError, but got %v", err)
+	}
+	if pe.Line != 3 || pe.Field != "Price" || pe.Column != "totalPrice" || pe.Value != "n/a" {
+		t.Errorf("For the bad price, wanted line 3, field Price, column totalPrice and value n/a, but got %+v", *pe)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("The ParseError should wrap the conversion error, but wrapped %v", pe.Err)
+	}
+}
+
+func TestBadDateDoesNotPanic(t *testing.T) {
+	_, err := parseLines([][]string{{"product_ok", "customer_ok", "03/02/2001", "100.00"}}, rawFieldMap)
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("parseLines should have returned a *ParseError for a bad date, but got %v", err)
+	}
+	if pe.Line != 1 || pe.Field != "PurchaseDate" || pe.Value != "03/02/2001" {
+		t.Errorf("For the bad date, wanted line 1, field PurchaseDate and value 03/02/2001, but got %+v", *pe)
+	}
+}
+
+func TestParseLinesErrorsOnShortRow(t *testing.T) {
+	_, err := parseLines([][]string{{"product_ok", "customer_ok"}}, rawFieldMap)
+	if !errors.Is(err, MissingCellError) {
+		t.Errorf("parseLines should have returned MissingCellError for a short row, but got %v", err)
+	}
+}
+
+func TestParseLinesErrorsOnNegativeRequiredIndex(t *testing.T) {
+	fieldMap := *rawFieldMap
+	fieldMap.CustomerID = -1
+	_, err := parseLines([][]string{{"product_ok", "customer_ok", "2001-02-03", "100.00"}}, &fieldMap)
+
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, MissingCellError) || pe.Field != "CustomerID" {
+		t.Errorf("parseLines should have returned a MissingCellError for CustomerID, but got %v", err)
+	}
+}
+
+func TestLenientReaderSkipsBadRows(t *testing.T) {
+	r, err := NewReader(strings.NewReader(dirtyLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := r.Lenient(-1).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clean) != 3 {
+		t.Errorf("A lenient Reader should have kept the 3 good rows, but kept %d", len(clean))
+	}
+	// rows keep their position for built fields, even when earlier rows were skipped
+	if clean[2].Uuid != 4 {
+		t.Errorf("The last good row should have kept UUID 4, but got %d", clean[2].Uuid)
+	}
+
+	rejected := r.Rejected()
+	if rejected.Rows != 6 || len(rejected.Errors) != 3 {
+		t.Fatalf("Wanted 3 of 6 rows rejected, but got %d of %d", len(rejected.Errors), rejected.Rows)
+	}
+	wantLines := []int{3, 5, 7}
+	for i, e := range rejected.Errors {
+		if e.Line != wantLines[i] {
+			t.Errorf("For rejection %d, wanted line %d, but got %d", i, wantLines[i], e.Line)
+		}
+	}
+
+	counts := rejected.ByColumn()
+	if counts["totalPrice"] != 1 || counts["purchaseDate"] != 1 || counts["(row)"] != 1 {
+		t.Errorf("ByColumn should have counted one rejection each for totalPrice, purchaseDate and (row), but got %v", counts)
+	}
+	if !strings.HasPrefix(rejected.Summary(), "3 of 6 rows rejected from input") {
+		t.Errorf("Summary should have started with the rejected count, but was:\n%s", rejected.Summary())
+	}
+}
+
+func TestLenientReaderStopsOverBudget(t *testing.T) {
+	r, err := NewReader(strings.NewReader(dirtyLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Lenient(1).ReadAll()
+	if !errors.Is(err, ErrorBudgetExceeded) {
+		t.Errorf("A Reader with a budget of 1 should have returned ErrorBudgetExceeded, but got %v", err)
+	}
+	if len(r.Rejected().Errors) != 2 {
+		t.Errorf("The Reader should have stopped at the second rejection, but rejected %d", len(r.Rejected().Errors))
+	}
+}
+
+func TestParseCSVLenientReportsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dirty.csv")
+	if err := os.WriteFile(path, []byte(dirtyLines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, rejected, err := ParseCSVLenient(path, 1, rawFieldMap, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clean) != 3 || rejected.File != path {
+		t.Errorf("Wanted 3 clean rows and rejections from %s, but got %d rows and rejections from %s", path, len(clean), rejected.File)
+	}
+	if !strings.HasPrefix(rejected.Errors[0].Error(), path+":3: column totalPrice") {
+		t.Errorf("The error should start with the file, line and column, but was: %s", rejected.Errors[0])
+	}
+
+	var b strings.Builder
+	if err := rejected.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != 4 {
+		t.Errorf("WriteCSV should have written a header and 3 rows, but wrote %d lines", lines)
+	}
+}
+
+func TestLenientReaderStillFailsOnReadErrors(t *testing.T) {
+	r, err := NewReader(io.MultiReader(strings.NewReader(rawLines), failingReader{}), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Lenient(-1).ReadAll(); err != io.ErrUnexpectedEOF {
+		t.Errorf("A lenient Reader should still stop on a read error, but got %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
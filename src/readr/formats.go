@@ -0,0 +1,84 @@
+package readr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat describes how a date column is written. Layouts are tried in order, so the most
+// common layout of an export should come first.
+type DateFormat struct {
+	Layouts  []string
+	Location *time.Location // for layouts without a zone; nil is UTC
+}
+
+// Parse reads a date using the first of the layouts which fits
+func (f *DateFormat) Parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	layouts := f.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultFormats.PurchaseDate.Layouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date does not match any of the layouts [%s]", strings.Join(layouts, ", "))
+}
+
+// NumberFormat describes how a number column is written
+type NumberFormat struct {
+	Decimal   rune     // the decimal separator; zero is '.'
+	Thousands rune     // the grouping separator, which is dropped; zero for none
+	Currency  []string // symbols or codes to strip, such as "$", "€" or "EUR"
+}
+
+// ParseFloat reads a number after stripping any currency and grouping
+func (f *NumberFormat) ParseFloat(s string) (float64, error) {
+	for _, symbol := range f.Currency {
+		s = strings.Replace(s, symbol, "", 1)
+	}
+	s = strings.TrimSpace(s)
+
+	if f.Thousands != 0 {
+		s = strings.ReplaceAll(s, string(f.Thousands), "")
+	}
+	if f.Decimal != 0 && f.Decimal != '.' {
+		// a '.' which is not the decimal separator must not be read as one
+		if strings.ContainsRune(s, '.') {
+			return 0, fmt.Errorf("unexpected '.' in a number using %q as its decimal separator", f.Decimal)
+		}
+		s = strings.Replace(s, string(f.Decimal), ".", 1)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Formats configures how readr parses the columns which are not plain text
+type Formats struct {
+	PurchaseDate DateFormat
+	Price        NumberFormat
+}
+
+var DefaultFormats Formats = Formats{
+	PurchaseDate: DateFormat{Layouts: []string{"2006-01-02"}},
+	Price:        NumberFormat{Decimal: '.'},
+}
+
+// Some common layouts and number formats of ERP exports
+var (
+	EuropeanDate = DateFormat{Layouts: []string{"02.01.2006", "2006-01-02"}}
+	USDate       = DateFormat{Layouts: []string{"01/02/2006", "1/2/2006", "2006-01-02"}}
+	// ISO dates, optionally with a time and zone
+	TimestampDate = DateFormat{Layouts: []string{time.RFC3339, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02"}}
+
+	EuropeanNumber = NumberFormat{Decimal: ',', Thousands: '.'}
+	USNumber       = NumberFormat{Decimal: '.', Thousands: ','}
+)
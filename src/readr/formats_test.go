@@ -0,0 +1,114 @@
+package readr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNumberFormatParseFloat(t *testing.T) {
+	type testCase struct {
+		format NumberFormat
+		raw    string
+		want   float64
+	}
+	testCases := []testCase{
+		{DefaultFormats.Price, "101.14", 101.14},
+		{EuropeanNumber, "1.234,56", 1234.56},
+		{EuropeanNumber, "-0,5", -0.5},
+		{USNumber, "1,234.00", 1234},
+		{NumberFormat{Decimal: '.', Thousands: ',', Currency: []string{"$"}}, "$1,234.00", 1234},
+		{NumberFormat{Decimal: '.', Thousands: ',', Currency: []string{"$"}}, "-$12.50", -12.5},
+		{NumberFormat{Decimal: ',', Thousands: ' ', Currency: []string{"EUR", "€"}}, "1 234,56 €", 1234.56},
+		{NumberFormat{Decimal: ',', Thousands: ' ', Currency: []string{"EUR", "€"}}, "EUR 99,90", 99.9},
+	}
+
+	for _, tc := range testCases {
+		got, err := tc.format.ParseFloat(tc.raw)
+		if err != nil {
+			t.Errorf("For %q, ParseFloat errored unexpectedly with %s", tc.raw, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("For %q, wanted %.2f, but got %.2f", tc.raw, tc.want, got)
+		}
+	}
+}
+
+func TestNumberFormatRejectsForeignDecimal(t *testing.T) {
+	// with a comma for decimals and no grouping, a point is a mistake rather than a separator
+	f := NumberFormat{Decimal: ','}
+	if _, err := f.ParseFloat("1.234"); err == nil {
+		t.Errorf("ParseFloat should have rejected a '.' in a number using ',' for decimals, but didn't")
+	}
+}
+
+func TestDateFormatParse(t *testing.T) {
+	berlin := time.FixedZone("CET", 60*60)
+	type testCase struct {
+		format DateFormat
+		raw    string
+		want   time.Time
+	}
+	testCases := []testCase{
+		{DefaultFormats.PurchaseDate, "2017-02-28", time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{EuropeanDate, "28.02.2017", time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{USDate, "02/28/2017", time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{USDate, "2/8/2017", time.Date(2017, 2, 8, 0, 0, 0, 0, time.UTC)},
+		{USDate, "2017-02-28", time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{TimestampDate, "2017-02-28T23:30:00-05:00", time.Date(2017, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{DateFormat{Layouts: EuropeanDate.Layouts, Location: berlin}, "28.02.2017", time.Date(2017, 2, 27, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		got, err := tc.format.Parse(tc.raw)
+		if err != nil {
+			t.Errorf("For %q, Parse errored unexpectedly with %s", tc.raw, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("For %q, wanted %s, but got %s", tc.raw, tc.want, got)
+		}
+	}
+
+	if _, err := EuropeanDate.Parse("02/28/2017"); err == nil {
+		t.Errorf("EuropeanDate should not have parsed a US date, but did")
+	}
+}
+
+func TestReaderWithFormats(t *testing.T) {
+	european := `productId,customerId,purchaseDate,totalPrice
+bed_bath_table:8,15df0,28.02.2017,"1.101,14 €"
+`
+	r, err := NewReaderByHeader(strings.NewReader(european), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WithFormats(&Formats{
+		PurchaseDate: EuropeanDate,
+		Price:        NumberFormat{Decimal: ',', Thousands: '.', Currency: []string{"€"}},
+	})
+
+	got, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != 1101.14 || !got.Period.Equal(time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wanted a price of 1101.14 on 2017-02-28, but got %+v", got)
+	}
+}
+
+func TestReaderWithNilFormatsUsesDefaults(t *testing.T) {
+	r, err := NewReaderByHeader(strings.NewReader("productId,customerId,purchaseDate,totalPrice\nbed_bath_table:8,15df0,2017-02-28,101.14\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.WithFormats(nil).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != 101.14 {
+		t.Errorf("Wanted a price of 101.14, but got %+v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package readr
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+// FieldNameMap provides readr with the header names each field's column may go by, for exports
+// whose column order cannot be relied upon. Names are matched ignoring case and surrounding
+// whitespace, and the first name found in the header wins.
+// UUID and the document fields are optional: when none of their names are in the header, readr
+// "builds" them using the row index, as a negative index does in a FieldIndexMap.
+type FieldNameMap struct {
+	UUID               []string
+	ProductID          []string
+	CustomerID         []string
+	PurchaseDate       []string
+	DocumentNumber     []string
+	DocumentLineNumber []string
+	Price              []string
+}
+
+var DefaultFieldNames FieldNameMap = FieldNameMap{
+	UUID:               []string{"uuid", "id"},
+	ProductID:          []string{"productId", "product_id", "product"},
+	CustomerID:         []string{"customerId", "customer_id", "customer"},
+	PurchaseDate:       []string{"purchaseDate", "purchase_date", "date"},
+	DocumentNumber:     []string{"documentNumber", "document_number", "docNumber"},
+	DocumentLineNumber: []string{"documentLineNumber", "document_line_number", "docLineNumber", "lineNumber"},
+	Price:              []string{"price", "totalPrice", "total_price", "unitPrice"},
+}
+
+// MissingColumnsError lists the required fields which none of the header's columns matched
+type MissingColumnsError struct {
+	Fields []string
+	Header []string
+}
+
+func (e *MissingColumnsError) Error() string {
+	return fmt.Sprintf("required columns %s were not found in header [%s]", strings.Join(e.Fields, ", "), strings.Join(e.Header, ", "))
+}
+
+func normalizeHeader(name string) string {
+	return strings.ToLower(normalizeColumn(name))
+}
+
+// normalizeColumn tidies a header name for display
+func normalizeColumn(name string) string {
+	// some ERP exports lead with a UTF-8 byte order mark
+	return strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+}
+
+// Resolve finds the index of each field's column in the header row
+func (m *FieldNameMap) Resolve(header []string) (*FieldIndexMap, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		key := normalizeHeader(name)
+		if _, seen := positions[key]; !seen {
+			positions[key] = i
+		}
+	}
+
+	var missing []string
+	find := func(field string, names []string, required bool) int {
+		for _, name := range names {
+			if i, ok := positions[normalizeHeader(name)]; ok {
+				return i
+			}
+		}
+		if required {
+			missing = append(missing, field)
+		}
+		return -1
+	}
+
+	fieldMap := &FieldIndexMap{
+		UUID:               find("UUID", m.UUID, false),
+		ProductID:          find("ProductID", m.ProductID, true),
+		CustomerID:         find("CustomerID", m.CustomerID, true),
+		PurchaseDate:       find("PurchaseDate", m.PurchaseDate, true),
+		DocumentNumber:     find("DocumentNumber", m.DocumentNumber, false),
+		DocumentLineNumber: find("DocumentLineNumber", m.DocumentLineNumber, false),
+		Price:              find("Price", m.Price, true),
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingColumnsError{Fields: missing, Header: header}
+	}
+	return fieldMap, nil
+}
+
+// NewReaderByHeader returns a Reader over csv data whose first row is a header, finding each
+// field's column by name. A nil names uses DefaultFieldNames.
+func NewReaderByHeader(in io.Reader, names *FieldNameMap) (*Reader, error) {
+	if names == nil {
+		names = &DefaultFieldNames
+	}
+
+	c := csv.NewReader(in)
+	header, err := c.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fieldMap, err := names.Resolve(header)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ReuseRecord = true
+	return &Reader{csv: c, fieldMap: fieldMap, formats: &DefaultFormats, line: 1, header: append([]string(nil), header...)}, nil
+}
+
+// OpenCSVByHeader opens the csv at the given filepath for streaming, finding each field's column
+// by name. The Reader must be closed.
+func OpenCSVByHeader(filepath string, names *FieldNameMap) (*Reader, error) {
+	csvFile, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReaderByHeader(csvFile, names)
+	if err != nil {
+		csvFile.Close()
+		return nil, err
+	}
+	r.closer = csvFile
+	r.file = filepath
+	return r, nil
+}
+
+// ParseCSVByHeader is ParseCSV for a csv with a header row, finding each field's column by name
+func ParseCSVByHeader(filepath string, names *FieldNameMap) ([]structs.PriceRecord, error) {
+	r, err := OpenCSVByHeader(filepath, names)
+	if err != nil {
+		return []structs.PriceRecord{}, err
+	}
+	defer r.Close()
+
+	return r.ReadAll()
+}
@@ -0,0 +1,97 @@
+package readr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveIgnoresOrderAndCase(t *testing.T) {
+	header := []string{" TotalPrice", "PURCHASEDATE", "customerid", "ProductId"}
+	got, err := DefaultFieldNames.Resolve(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := FieldIndexMap{
+		UUID:               -1,
+		ProductID:          3,
+		CustomerID:         2,
+		PurchaseDate:       1,
+		DocumentNumber:     -1,
+		DocumentLineNumber: -1,
+		Price:              0,
+	}
+	if *got != want {
+		t.Errorf("Resolve should have returned %+v, but got %+v", want, *got)
+	}
+}
+
+func TestResolveUsesFirstAliasFound(t *testing.T) {
+	names := FieldNameMap{
+		ProductID:    []string{"sku", "product"},
+		CustomerID:   []string{"customer"},
+		PurchaseDate: []string{"date"},
+		Price:        []string{"net", "gross"},
+	}
+	got, err := names.Resolve([]string{"product", "sku", "customer", "date", "gross", "net"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ProductID != 1 || got.Price != 5 {
+		t.Errorf("Resolve should have preferred the first alias of each field, but got %+v", *got)
+	}
+}
+
+func TestResolveReportsMissingColumns(t *testing.T) {
+	_, err := DefaultFieldNames.Resolve([]string{"productId", "date"})
+
+	var missing *MissingColumnsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Resolve should have returned a MissingColumnsError, but got %v", err)
+	}
+	if len(missing.Fields) != 2 || missing.Fields[0] != "CustomerID" || missing.Fields[1] != "Price" {
+		t.Errorf("Resolve should have reported CustomerID and Price as missing, but got %v", missing.Fields)
+	}
+	if !strings.Contains(err.Error(), "CustomerID, Price") {
+		t.Errorf("The error should name the missing columns, but was: %s", err)
+	}
+}
+
+func TestNewReaderByHeader(t *testing.T) {
+	// rawLines with its columns reordered, and a byte order mark
+	reordered := "\ufefftotalPrice,purchaseDate,productId,customerId\n" +
+		"101.14,2017-02-28,bed_bath_table:8,15df0\n" +
+		"104.7,2017-02-28,bed_bath_table:8,f4c13\n"
+
+	r, err := NewReaderByHeader(strings.NewReader(reordered), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range priceRecords[:2] {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Uuid != want.Uuid || got.ProductID != want.ProductID || got.CustomerID != want.CustomerID || !got.Period.Equal(want.Period) || got.Value != want.Value || got.DocumentNumber != want.DocumentNumber {
+			t.Errorf("For CSV Line %d, wanted %+v, but got %+v", i, want, got)
+		}
+	}
+}
+
+func TestParseCSVByHeader(t *testing.T) {
+	parsed, err := ParseCSVByHeader(rawCsvPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed) != len(priceRecords) {
+		t.Fatalf("ParseCSVByHeader should have parsed %d records, but got %d", len(priceRecords), len(parsed))
+	}
+	for i, got := range parsed {
+		want := priceRecords[i]
+		if got.Uuid != want.Uuid || got.ProductID != want.ProductID || got.DocumentLineNumber != want.DocumentLineNumber || got.Value != want.Value {
+			t.Errorf("For CSV Line %d, wanted %+v, but got %+v", i, want, got)
+		}
+	}
+}
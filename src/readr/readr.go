@@ -1,13 +1,10 @@
 package readr
 
 import (
-	"encoding/csv"
 	"errors"
-	"os"
 	"strconv"
 
 	"github.com/Viking2012/goraynor/src/structs"
-	"github.com/Viking2012/goraynor/src/utils"
 )
 
 // FieldIndexMap provides a structure which enables readr to find data fields in the csv provided.
@@ -23,6 +20,9 @@ type FieldIndexMap struct {
 	Price              int
 }
 
+var PriceIndexMissing error = errors.New("Index for price must be provided")
+var RequiredIndexMissing error = errors.New("Indexes for product, customer and purchase date must be provided")
+
 var DefaultFieldMap FieldIndexMap = FieldIndexMap{
 	UUID:               0,
 	ProductID:          1,
@@ -33,38 +33,29 @@ var DefaultFieldMap FieldIndexMap = FieldIndexMap{
 	Price:              6,
 }
 
-// ParseCSV reads a csv at the given filepath and converts it to pricing records we can examine
+// ParseCSV reads a csv at the given filepath and converts it to pricing records we can examine.
+// Every record is held in memory; use OpenCSV to stream large files instead.
 func ParseCSV(filepath string, headerRows int, fieldMap *FieldIndexMap) ([]structs.PriceRecord, error) {
-	// if fieldMap was a nil pointer, then use the default map
-	if fieldMap == nil {
-		fieldMap = &DefaultFieldMap
-	}
-
-	// Price MUST be provided - without it, what are we running this for?
-	if fieldMap.Price < 0 {
-		return []structs.PriceRecord{}, errors.New("Index for price must be provided")
-	}
-
-	csvFile, err := os.Open(filepath)
-
+	r, err := OpenCSV(filepath, headerRows, fieldMap)
 	if err != nil {
 		return []structs.PriceRecord{}, err
 	}
+	defer r.Close()
 
-	r := csv.NewReader(csvFile)
-
-	lines, err := r.ReadAll()
-
-	var clean []structs.PriceRecord
-
-	clean, err = parseLines(lines[headerRows:], fieldMap)
+	return r.ReadAll()
+}
 
+// ParseCSVLenient is ParseCSV, but skips rows which cannot be parsed rather than failing, up to
+// budget rejected rows (negative for no limit). The skipped rows are returned for reporting.
+func ParseCSVLenient(filepath string, headerRows int, fieldMap *FieldIndexMap, budget int) ([]structs.PriceRecord, *Rejections, error) {
+	r, err := OpenCSV(filepath, headerRows, fieldMap)
 	if err != nil {
-		return []structs.PriceRecord{}, err
+		return []structs.PriceRecord{}, nil, err
 	}
+	defer r.Close()
 
-	return clean, nil
-
+	clean, err := r.Lenient(budget).ReadAll()
+	return clean, r.Rejected(), err
 }
 
 func extractIntField(record []string, givenIndex, i int) (int, error) {
@@ -76,13 +67,15 @@ func extractIntField(record []string, givenIndex, i int) (int, error) {
 	return r, err
 }
 
-func extractFloatField(record []string, givenIndex, i int) (float64, error) {
+func extractFloatField(record []string, givenIndex, i int, format *NumberFormat) (float64, error) {
 	if givenIndex < 0 {
 		return float64(i), nil
 	}
 
-	r, err := strconv.ParseFloat(record[givenIndex], 64)
-	return r, err
+	if format == nil {
+		return strconv.ParseFloat(record[givenIndex], 64)
+	}
+	return format.ParseFloat(record[givenIndex])
 }
 
 // parseLines allows for easier testing (can take an array of an array of strings from any source location)
@@ -91,36 +84,57 @@ func parseLines(lines [][]string, fieldMap *FieldIndexMap) ([]structs.PriceRecor
 	priceRecords := make([]structs.PriceRecord, numLines)
 
 	for i := 0; i < numLines; i++ {
-		thisUuid, err := extractIntField(lines[i], fieldMap.UUID, i)
+		pr, err := parseLine(lines[i], fieldMap, &DefaultFormats, i)
 		if err != nil {
+			err.Line = i + 1
 			return []structs.PriceRecord{}, err
 		}
+		priceRecords[i] = pr
+	}
 
-		thisPrice, err := extractFloatField(lines[i], fieldMap.Price, i)
-		if err != nil {
-			return []structs.PriceRecord{}, err
-		}
+	return priceRecords, nil
+}
 
-		thisDoc, err := extractIntField(lines[i], fieldMap.DocumentNumber, i)
-		if err != nil {
-			return []structs.PriceRecord{}, err
-		}
+// parseLine converts a single csv line into a PriceRecord. i is the index of the line among the
+// data rows, which is used for any field the fieldMap asks to be built from the row index.
+// The caller is left to fill in where the line came from on any error returned.
+func parseLine(line []string, fieldMap *FieldIndexMap, formats *Formats, i int) (structs.PriceRecord, *ParseError) {
+	if err := checkWidth(line, fieldMap); err != nil {
+		return structs.PriceRecord{}, err
+	}
 
-		thisDocNum, err := extractIntField(lines[i], fieldMap.DocumentLineNumber, i)
-		if err != nil {
-			return []structs.PriceRecord{}, err
-		}
+	thisUuid, err := extractIntField(line, fieldMap.UUID, i)
+	if err != nil {
+		return structs.PriceRecord{}, cellError(line, "UUID", fieldMap.UUID, err)
+	}
 
-		priceRecords[i] = structs.PriceRecord{
-			Uuid:               int64(thisUuid),
-			ProductID:          lines[i][fieldMap.ProductID],
-			CustomerID:         lines[i][fieldMap.CustomerID],
-			PurchaseDate:       utils.QuickParse(lines[i][fieldMap.PurchaseDate]),
-			Price:              thisPrice,
-			DocumentNumber:     int64(thisDoc),
-			DocumentLineNumber: int64(thisDocNum),
-		}
+	thisPrice, err := extractFloatField(line, fieldMap.Price, i, &formats.Price)
+	if err != nil {
+		return structs.PriceRecord{}, cellError(line, "Price", fieldMap.Price, err)
 	}
 
-	return priceRecords, nil
+	thisDoc, err := extractIntField(line, fieldMap.DocumentNumber, i)
+	if err != nil {
+		return structs.PriceRecord{}, cellError(line, "DocumentNumber", fieldMap.DocumentNumber, err)
+	}
+
+	thisDocNum, err := extractIntField(line, fieldMap.DocumentLineNumber, i)
+	if err != nil {
+		return structs.PriceRecord{}, cellError(line, "DocumentLineNumber", fieldMap.DocumentLineNumber, err)
+	}
+
+	thisDate, err := formats.PurchaseDate.Parse(line[fieldMap.PurchaseDate])
+	if err != nil {
+		return structs.PriceRecord{}, cellError(line, "PurchaseDate", fieldMap.PurchaseDate, err)
+	}
+
+	return structs.PriceRecord{
+		Uuid:               int64(thisUuid),
+		ProductID:          line[fieldMap.ProductID],
+		CustomerID:         line[fieldMap.CustomerID],
+		Period:             thisDate,
+		Value:              thisPrice,
+		DocumentNumber:     int64(thisDoc),
+		DocumentLineNumber: int64(thisDocNum),
+	}, nil
 }
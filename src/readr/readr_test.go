@@ -30,11 +30,11 @@ bed_bath_table:8,2ed85,2017-03-04,101.14
 	}
 
 	priceRecords []structs.PriceRecord = []structs.PriceRecord{
-		{Uuid: 0, ProductID: "bed_bath_table:8", CustomerID: "15df0", PurchaseDate: utils.QuickParse("2017-02-28"), DocumentNumber: 0, DocumentLineNumber: 0, Price: 101.14},
-		{Uuid: 1, ProductID: "bed_bath_table:8", CustomerID: "f4c13", PurchaseDate: utils.QuickParse("2017-02-28"), DocumentNumber: 1, DocumentLineNumber: 1, Price: 104.70},
-		{Uuid: 2, ProductID: "bed_bath_table:9", CustomerID: "0dc4b", PurchaseDate: utils.QuickParse("2017-03-01"), DocumentNumber: 2, DocumentLineNumber: 2, Price: 101.14},
-		{Uuid: 3, ProductID: "bed_bath_table:8", CustomerID: "d98e2", PurchaseDate: utils.QuickParse("2017-03-02"), DocumentNumber: 3, DocumentLineNumber: 3, Price: 104.70},
-		{Uuid: 4, ProductID: "bed_bath_table:8", CustomerID: "2ed85", PurchaseDate: utils.QuickParse("2017-03-04"), DocumentNumber: 4, DocumentLineNumber: 4, Price: 101.14},
+		{Uuid: 0, ProductID: "bed_bath_table:8", CustomerID: "15df0", Period: utils.QuickParse("2017-02-28"), DocumentNumber: 0, DocumentLineNumber: 0, Value: 101.14},
+		{Uuid: 1, ProductID: "bed_bath_table:8", CustomerID: "f4c13", Period: utils.QuickParse("2017-02-28"), DocumentNumber: 1, DocumentLineNumber: 1, Value: 104.70},
+		{Uuid: 2, ProductID: "bed_bath_table:9", CustomerID: "0dc4b", Period: utils.QuickParse("2017-03-01"), DocumentNumber: 2, DocumentLineNumber: 2, Value: 101.14},
+		{Uuid: 3, ProductID: "bed_bath_table:8", CustomerID: "d98e2", Period: utils.QuickParse("2017-03-02"), DocumentNumber: 3, DocumentLineNumber: 3, Value: 104.70},
+		{Uuid: 4, ProductID: "bed_bath_table:8", CustomerID: "2ed85", Period: utils.QuickParse("2017-03-04"), DocumentNumber: 4, DocumentLineNumber: 4, Value: 101.14},
 	}
 
 	badLine [][]string = [][]string{
@@ -62,8 +62,8 @@ func TestParseCsvSkipsLines(t *testing.T) {
 		if want.CustomerID != got.CustomerID {
 			t.Errorf("For CSV Line %d, wanted         CustomerID: %s, but got: %s", i, want.CustomerID, got.CustomerID)
 		}
-		if !want.PurchaseDate.Equal(got.PurchaseDate) {
-			t.Errorf("For CSV Line %d, wanted       PurchaseDate: %s, but got: %s", i, want.PurchaseDate.Format("2006-01-02"), got.PurchaseDate.Format("2006-01-02"))
+		if !want.Period.Equal(got.Period) {
+			t.Errorf("For CSV Line %d, wanted             Period: %s, but got: %s", i, want.Period.Format("2006-01-02"), got.Period.Format("2006-01-02"))
 		}
 		if want.DocumentNumber != got.DocumentNumber {
 			t.Errorf("For CSV Line %d, wanted     DocumentNumber: %d, but got: %d", i, want.DocumentNumber, got.DocumentNumber)
@@ -71,8 +71,8 @@ func TestParseCsvSkipsLines(t *testing.T) {
 		if want.DocumentLineNumber != got.DocumentLineNumber {
 			t.Errorf("For CSV Line %d, wanted DocumentLineNumber: %d, but got: %d", i, want.DocumentLineNumber, got.DocumentLineNumber)
 		}
-		if want.Price != got.Price {
-			t.Errorf("For CSV Line %d, wanted              Price: %.2F, but got: %.2F", i, want.Price, got.Price)
+		if want.Value != got.Value {
+			t.Errorf("For CSV Line %d, wanted              Value: %.2F, but got: %.2F", i, want.Value, got.Value)
 		}
 	}
 }
@@ -95,8 +95,8 @@ func TestParseCsvUsesDefaultFieldMap(t *testing.T) {
 		if want.CustomerID != got.CustomerID {
 			t.Errorf("For CSV Line %d, wanted         CustomerID: %s, but got: %s", i, want.CustomerID, got.CustomerID)
 		}
-		if !want.PurchaseDate.Equal(got.PurchaseDate) {
-			t.Errorf("For CSV Line %d, wanted       PurchaseDate: %s, but got: %s", i, want.PurchaseDate.Format("2006-01-02"), got.PurchaseDate.Format("2006-01-02"))
+		if !want.Period.Equal(got.Period) {
+			t.Errorf("For CSV Line %d, wanted             Period: %s, but got: %s", i, want.Period.Format("2006-01-02"), got.Period.Format("2006-01-02"))
 		}
 		if want.DocumentNumber != got.DocumentNumber {
 			t.Errorf("For CSV Line %d, wanted     DocumentNumber: %d, but got: %d", i, want.DocumentNumber, got.DocumentNumber)
@@ -104,8 +104,8 @@ func TestParseCsvUsesDefaultFieldMap(t *testing.T) {
 		if want.DocumentLineNumber != got.DocumentLineNumber {
 			t.Errorf("For CSV Line %d, wanted DocumentLineNumber: %d, but got: %d", i, want.DocumentLineNumber, got.DocumentLineNumber)
 		}
-		if want.Price != got.Price {
-			t.Errorf("For CSV Line %d, wanted              Price: %.2F, but got: %.2F", i, want.Price, got.Price)
+		if want.Value != got.Value {
+			t.Errorf("For CSV Line %d, wanted              Value: %.2F, but got: %.2F", i, want.Value, got.Value)
 		}
 	}
 }
@@ -153,8 +153,8 @@ func TestParseLines(t *testing.T) {
 		if want.CustomerID != got.CustomerID {
 			t.Errorf("For CSV Line %d, wanted         CustomerID: %s, but got: %s", i, want.CustomerID, got.CustomerID)
 		}
-		if !want.PurchaseDate.Equal(got.PurchaseDate) {
-			t.Errorf("For CSV Line %d, wanted       PurchaseDate: %s, but got: %s", i, want.PurchaseDate.Format("2006-01-02"), got.PurchaseDate.Format("2006-01-02"))
+		if !want.Period.Equal(got.Period) {
+			t.Errorf("For CSV Line %d, wanted             Period: %s, but got: %s", i, want.Period.Format("2006-01-02"), got.Period.Format("2006-01-02"))
 		}
 		if want.DocumentNumber != got.DocumentNumber {
 			t.Errorf("For CSV Line %d, wanted     DocumentNumber: %d, but got: %d", i, want.DocumentNumber, got.DocumentNumber)
@@ -162,8 +162,8 @@ func TestParseLines(t *testing.T) {
 		if want.DocumentLineNumber != got.DocumentLineNumber {
 			t.Errorf("For CSV Line %d, wanted DocumentLineNumber: %d, but got: %d", i, want.DocumentLineNumber, got.DocumentLineNumber)
 		}
-		if want.Price != got.Price {
-			t.Errorf("For CSV Line %d, wanted              Price: %.2F, but got: %.2F", i, want.Price, got.Price)
+		if want.Value != got.Value {
+			t.Errorf("For CSV Line %d, wanted              Value: %.2F, but got: %.2F", i, want.Value, got.Value)
 		}
 	}
 }
@@ -220,7 +220,7 @@ func TestExtractIntFieldHandlesNegativeIndex(t *testing.T) {
 
 func TestExtractFloatFieldHandlesNegativeIndex(t *testing.T) {
 	want := float64(10)
-	got, err := extractFloatField([]string{"99.9"}, -1, 10)
+	got, err := extractFloatField([]string{"99.9"}, -1, 10, nil)
 
 	if err != nil {
 		t.Error(err)
@@ -0,0 +1,230 @@
+package readr
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+// Reader streams pricing records out of a csv one row at a time, so that memory use does not
+// grow with the size of the file
+type Reader struct {
+	csv      *csv.Reader
+	fieldMap *FieldIndexMap
+	row      int       // the index of the next data row, for fields built from the row index
+	line     int       // the line number of the last row read
+	closer   io.Closer // the underlying file, when opened by OpenCSV
+	file     string    // the path of the underlying file, for ParseErrors
+	header   []string  // the column names, when the csv has a header row
+
+	formats *Formats
+
+	lenient  bool
+	budget   int
+	rejected Rejections
+}
+
+// NewReader returns a Reader over csv data, skipping the first headerRows rows
+func NewReader(in io.Reader, headerRows int, fieldMap *FieldIndexMap) (*Reader, error) {
+	// if fieldMap was a nil pointer, then use the default map
+	if fieldMap == nil {
+		fieldMap = &DefaultFieldMap
+	}
+
+	// Price MUST be provided - without it, what are we running this for?
+	if fieldMap.Price < 0 {
+		return nil, PriceIndexMissing
+	}
+	// unlike the UUID and document fields, these cannot be built from the row index
+	if fieldMap.ProductID < 0 || fieldMap.CustomerID < 0 || fieldMap.PurchaseDate < 0 {
+		return nil, RequiredIndexMissing
+	}
+
+	r := &Reader{csv: csv.NewReader(in), fieldMap: fieldMap, formats: &DefaultFormats}
+	// each row is parsed before the next is read, so the row's backing array can be reused
+	r.csv.ReuseRecord = true
+
+	for i := 0; i < headerRows; i++ {
+		row, err := r.csv.Read()
+		if err != nil {
+			return nil, err
+		}
+		// the first header row names the columns; copied, as the csv reader reuses it
+		if i == 0 {
+			r.header = append([]string(nil), row...)
+		}
+	}
+	r.line = headerRows
+	return r, nil
+}
+
+// OpenCSV opens the csv at the given filepath for streaming. The Reader must be closed.
+func OpenCSV(filepath string, headerRows int, fieldMap *FieldIndexMap) (*Reader, error) {
+	csvFile, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(csvFile, headerRows, fieldMap)
+	if err != nil {
+		csvFile.Close()
+		return nil, err
+	}
+	r.closer = csvFile
+	r.file = filepath
+	return r, nil
+}
+
+// WithFormats makes the Reader parse dates and numbers as described by formats. A nil formats
+// uses DefaultFormats.
+func (r *Reader) WithFormats(formats *Formats) *Reader {
+	if formats == nil {
+		formats = &DefaultFormats
+	}
+	r.formats = formats
+	return r
+}
+
+// Lenient makes the Reader skip rows which fail to parse rather than stopping at them, up to
+// budget rejected rows. A negative budget allows any number. The skipped rows are kept in
+// Rejected.
+func (r *Reader) Lenient(budget int) *Reader {
+	r.lenient = true
+	r.budget = budget
+	return r
+}
+
+// Rejected returns the rows skipped so far by a lenient Reader
+func (r *Reader) Rejected() *Rejections {
+	r.rejected.File = r.file
+	r.rejected.Rows = r.row
+	return &r.rejected
+}
+
+// Close closes the underlying file, if the Reader was opened with OpenCSV
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Next returns the next record, or io.EOF once every row has been read. A row which cannot be
+// parsed is returned as a *ParseError, unless the Reader is lenient, in which case it is skipped
+// and ErrorBudgetExceeded is returned once more rows have been skipped than the budget allows.
+func (r *Reader) Next() (structs.PriceRecord, error) {
+	for {
+		pr, err := r.next()
+		if err == nil {
+			return pr, nil
+		}
+
+		var pe *ParseError
+		if !r.lenient || !errors.As(err, &pe) {
+			return structs.PriceRecord{}, err
+		}
+
+		r.rejected.Errors = append(r.rejected.Errors, pe)
+		if r.budget >= 0 && len(r.rejected.Errors) > r.budget {
+			return structs.PriceRecord{}, fmt.Errorf("%w: %d rows rejected, allowed %d; last was %v", ErrorBudgetExceeded, len(r.rejected.Errors), r.budget, pe)
+		}
+	}
+}
+
+// next reads and parses a single row
+func (r *Reader) next() (structs.PriceRecord, error) {
+	line, err := r.csv.Read()
+	if err == io.EOF {
+		return structs.PriceRecord{}, err
+	}
+	if err != nil {
+		var csvErr *csv.ParseError
+		if !errors.As(err, &csvErr) {
+			return structs.PriceRecord{}, err
+		}
+		// a malformed row still takes up a row index
+		r.row++
+		r.line = csvErr.Line
+		return structs.PriceRecord{}, &ParseError{File: r.file, Line: csvErr.StartLine, Err: csvErr.Err, index: -1}
+	}
+
+	r.line++
+	pr, pe := parseLine(line, r.fieldMap, r.formats, r.row)
+	r.row++
+	if pe != nil {
+		pe.File = r.file
+		pe.Line = r.line
+		if pe.index >= 0 && pe.index < len(r.header) {
+			pe.Column = normalizeColumn(r.header[pe.index])
+		}
+		return structs.PriceRecord{}, pe
+	}
+	return pr, nil
+}
+
+// Each calls fn with every remaining record, in file order. It stops at the first error
+// returned by fn or by parsing, or when ctx is done.
+func (r *Reader) Each(ctx context.Context, fn func(structs.PriceRecord) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(pr); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadAll reads every remaining record into memory
+func (r *Reader) ReadAll() ([]structs.PriceRecord, error) {
+	var clean []structs.PriceRecord
+	err := r.Each(context.Background(), func(pr structs.PriceRecord) error {
+		clean = append(clean, pr)
+		return nil
+	})
+	if err != nil {
+		return []structs.PriceRecord{}, err
+	}
+
+	return clean, nil
+}
+
+// Stream sends every remaining record down the returned channel, which holds at most buffer
+// records at a time. Both channels are closed once the file is exhausted, parsing fails or ctx
+// is done; the error channel receives the error, if there was one.
+func (r *Reader) Stream(ctx context.Context, buffer int) (<-chan structs.PriceRecord, <-chan error) {
+	records := make(chan structs.PriceRecord, buffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+
+		err := r.Each(ctx, func(pr structs.PriceRecord) error {
+			select {
+			case records <- pr:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return records, errs
+}
@@ -0,0 +1,144 @@
+package readr
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/countr"
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func TestReaderNextMatchesParseLines(t *testing.T) {
+	r, err := NewReader(strings.NewReader(rawLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range priceRecords {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("For CSV Line %d, Next errored unexpectedly with %s", i, err)
+		}
+		if got.Uuid != want.Uuid || got.ProductID != want.ProductID || got.CustomerID != want.CustomerID || got.Value != want.Value {
+			t.Errorf("For CSV Line %d, wanted %+v, but got %+v", i, want, got)
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next should have returned io.EOF after the last line, but got %v", err)
+	}
+}
+
+func TestNewReaderCatchesFieldMapWithoutPriceIndex(t *testing.T) {
+	_, err := NewReader(strings.NewReader(rawLines), 1, &FieldIndexMap{Price: -1})
+	if err != PriceIndexMissing {
+		t.Errorf("NewReader should have returned PriceIndexMissing, but got %v", err)
+	}
+}
+
+func TestNewReaderCatchesNegativeRequiredIndexes(t *testing.T) {
+	for _, modify := range []func(m *FieldIndexMap){
+		func(m *FieldIndexMap) { m.ProductID = -1 },
+		func(m *FieldIndexMap) { m.CustomerID = -1 },
+		func(m *FieldIndexMap) { m.PurchaseDate = -1 },
+	} {
+		fieldMap := *rawFieldMap
+		modify(&fieldMap)
+		if _, err := NewReader(strings.NewReader(rawLines), 1, &fieldMap); err != RequiredIndexMissing {
+			t.Errorf("For %+v, NewReader should have returned RequiredIndexMissing, but got %v", fieldMap, err)
+		}
+	}
+}
+
+func TestReaderStream(t *testing.T) {
+	r, err := OpenCSV(rawCsvPath, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	records, errs := r.Stream(context.Background(), 1)
+	var got []structs.PriceRecord
+	for pr := range records {
+		got = append(got, pr)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(priceRecords) {
+		t.Fatalf("Stream should have sent %d records, but sent %d", len(priceRecords), len(got))
+	}
+	for i, want := range priceRecords {
+		if got[i].Uuid != want.Uuid || got[i].Value != want.Value {
+			t.Errorf("For CSV Line %d, wanted %+v, but got %+v", i, want, got[i])
+		}
+	}
+}
+
+func TestReaderStreamStopsOnCancel(t *testing.T) {
+	r, err := NewReader(strings.NewReader(rawLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errs := r.Stream(ctx, 0)
+	<-records
+	cancel()
+
+	for range records {
+		// drain whatever was sent before the cancellation was noticed
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Stream should have stopped with context.Canceled, but got %v", err)
+	}
+}
+
+func TestReaderEachFeedsTally(t *testing.T) {
+	r, err := NewReader(strings.NewReader(rawLines), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tally := make(countr.Tally)
+	err = r.Each(context.Background(), func(pr structs.PriceRecord) error {
+		tally.Add(pr.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := tally.Counter()
+	want := countr.Counter{{Value: 101.14, Count: 3}, {Value: 104.7, Count: 2}}
+	if got.Len() != want.Len() {
+		t.Fatalf("Wanted %d unique prices, but got %d", want.Len(), got.Len())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("For index %d, wanted %v, but got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestReaderEachStopsOnParseError(t *testing.T) {
+	r, err := NewReader(strings.NewReader(rawLines+"bed_bath_table:8,2ed85,2017-03-04,BadFloat\n"), 1, rawFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen int
+	err = r.Each(context.Background(), func(pr structs.PriceRecord) error {
+		seen++
+		return nil
+	})
+	if err == nil {
+		t.Error("Each should have returned the bad float conversion error, but didn't")
+	}
+	if seen != len(priceRecords) {
+		t.Errorf("Each should have handed over the %d good records first, but handed over %d", len(priceRecords), seen)
+	}
+}
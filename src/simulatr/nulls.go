@@ -0,0 +1,92 @@
+package simulatr
+
+import (
+	"sync"
+
+	"github.com/Viking2012/goraynor/src/transitions"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+type nullKey struct {
+	start    int8
+	lifespan int
+}
+
+// Nulls simulates null distributions from a single model for whichever starting decile and
+// lifespan is asked for, caching each Result. The seed of each simulation is derived from the
+// base seed, the starting decile and the lifespan, so results do not depend on the order they
+// are asked for in. Nulls is safe for concurrent use.
+type Nulls struct {
+	model  transitions.Matrix
+	trials int
+	seed   uint64
+
+	mu    sync.Mutex
+	cache map[nullKey]Result
+}
+
+// NewNulls returns Nulls of trials simulated lifespans each, over the row-stochastic model
+func NewNulls(model transitions.Matrix, trials int, seed uint64) (*Nulls, error) {
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	if trials < 1 {
+		return nil, InvalidTrialsError
+	}
+	return &Nulls{
+		model:  model,
+		trials: trials,
+		seed:   seed,
+		cache:  make(map[nullKey]Result),
+	}, nil
+}
+
+// Get returns the simulated lifespans for a starting decile and lifespan
+func (n *Nulls) Get(start int8, lifespan int) (Result, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := nullKey{start: start, lifespan: lifespan}
+	if r, ok := n.cache[key]; ok {
+		return r, nil
+	}
+
+	r, err := Simulate(n.model, Config{
+		Start:    start,
+		Lifespan: lifespan,
+		Trials:   n.trials,
+		Seed:     n.seed ^ (uint64(start)<<32 | uint64(lifespan)),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	n.cache[key] = r
+	return r, nil
+}
+
+// Independent returns the transition matrix in which every period's decile is drawn from the
+// same distribution, whatever the previous period's was. Row i is the probability of each
+// decile under weights, whose category i is decile i+1.
+func Independent(weights distuv.Categorical) transitions.Matrix {
+	n := weights.Len()
+	row := make([]float64, n)
+	for i := range row {
+		row[i] = weights.Prob(float64(i))
+	}
+
+	m := make(transitions.Matrix, n)
+	for i := range m {
+		m[i] = append([]float64(nil), row...)
+	}
+	return m
+}
+
+// InDeciles reports whether d is one of the deciles
+func InDeciles(deciles []int8, d int8) bool {
+	for _, want := range deciles {
+		if d == want {
+			return true
+		}
+	}
+	return false
+}
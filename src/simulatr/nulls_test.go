@@ -0,0 +1,75 @@
+package simulatr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/transitions"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+func TestNullsDoNotDependOnOrder(t *testing.T) {
+	a, err := NewNulls(coin, 100, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewNulls(coin, 100, 42)
+
+	first, _ := a.Get(1, 5)
+	_, _ = b.Get(2, 8)
+	second, _ := b.Get(1, 5)
+
+	want, got := first.Periods(1), second.Periods(1)
+	for trial := range want {
+		if want[trial] != got[trial] {
+			t.Fatalf("For trial %d, wanted the same lifespan whatever was simulated first, but got %v and %v", trial, want[trial], got[trial])
+		}
+	}
+
+	cached, _ := a.Get(1, 5)
+	if cached.Seed != first.Seed || cached.Seed != 42^(uint64(1)<<32|5) {
+		t.Errorf("For the cached result, wanted seed %d, but got %d", first.Seed, cached.Seed)
+	}
+}
+
+func TestNullsAreSafeForConcurrentUse(t *testing.T) {
+	nulls, err := NewNulls(coin, 50, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(lifespan int) {
+			defer wg.Done()
+			for start := int8(1); start <= 2; start++ {
+				if _, err := nulls.Get(start, lifespan); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i%3 + 1)
+	}
+	wg.Wait()
+}
+
+func TestNewNullsRejectsBadInput(t *testing.T) {
+	if _, err := NewNulls(transitions.Matrix{{1, 1}, {0, 1}}, 10, 1); err != transitions.NotStochasticError {
+		t.Errorf("For an unnormalized matrix, wanted %v, but got %v", transitions.NotStochasticError, err)
+	}
+	if _, err := NewNulls(coin, 0, 1); err != InvalidTrialsError {
+		t.Errorf("For no trials, wanted %v, but got %v", InvalidTrialsError, err)
+	}
+}
+
+func TestIndependentRepeatsTheDistribution(t *testing.T) {
+	m := Independent(distuv.NewCategorical([]float64{1, 3}, nil))
+	want := transitions.Matrix{{0.25, 0.75}, {0.25, 0.75}}
+	for i := range want {
+		for j := range want[i] {
+			if m[i][j] != want[i][j] {
+				t.Errorf("For row %d, wanted %v, but got %v", i, want[i], m[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package simulatr
+
+import (
+	"errors"
+
+	"github.com/Viking2012/goraynor/src/countr"
+	"github.com/Viking2012/goraynor/src/transitions"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+var (
+	InvalidStartError    error = errors.New("the starting decile falls outside of the transition matrix")
+	InvalidLifespanError error = errors.New("a simulated lifespan must be at least one period long")
+	InvalidTrialsError   error = errors.New("at least one trial must be simulated")
+	InvalidAlphaError    error = errors.New("the significance level must be between 0 and 1")
+
+	// Deprecated: use transitions.NotStochasticError
+	NotStochasticError error = transitions.NotStochasticError
+	// Deprecated: use transitions.NegativeProbabilityError
+	NegativeProbabilityError error = transitions.NegativeProbabilityError
+)
+
+// Validate checks that a transition matrix is row-stochastic, and so can be simulated.
+//
+// Deprecated: use transitions.Matrix.Validate
+func Validate(m transitions.Matrix) error {
+	return m.Validate()
+}
+
+// Config describes a single simulation run
+type Config struct {
+	Start    int8   // the decile every trajectory starts in (1-indexed, as on PriceRecord.Bucket)
+	Lifespan int    // the number of periods in each trajectory, including the starting period
+	Trials   int    // the number of trajectories to simulate
+	Seed     uint64 // the random seed; the same seed and matrix always give the same Result
+}
+
+// Result holds the occupancy of every simulated trajectory: how many of its periods
+// were spent in each decile
+type Result struct {
+	Config
+	Deciles   int
+	occupancy []int // Trials rows of Deciles columns, flattened
+}
+
+// Simulate runs cfg.Trials Markov chain trajectories over the row-stochastic transition
+// matrix m. Each trajectory starts in cfg.Start and lasts cfg.Lifespan periods.
+func Simulate(m transitions.Matrix, cfg Config) (Result, error) {
+	if err := m.Validate(); err != nil {
+		return Result{}, err
+	}
+	n := m.Size()
+	if cfg.Start < 1 || int(cfg.Start) > n {
+		return Result{}, InvalidStartError
+	}
+	if cfg.Lifespan < 1 {
+		return Result{}, InvalidLifespanError
+	}
+	if cfg.Trials < 1 {
+		return Result{}, InvalidTrialsError
+	}
+
+	// every row shares one source, so the whole run is reproducible from the seed
+	src := rand.NewSource(cfg.Seed)
+	rows := make([]distuv.Categorical, n)
+	for i := 0; i < n; i++ {
+		rows[i] = distuv.NewCategorical(m[i], src)
+	}
+
+	r := Result{
+		Config:    cfg,
+		Deciles:   n,
+		occupancy: make([]int, cfg.Trials*n),
+	}
+
+	for trial := 0; trial < cfg.Trials; trial++ {
+		counts := r.occupancy[trial*n : (trial+1)*n]
+		// the categorical distributions, like the slices, are 0-indexed
+		thisIndex := int(cfg.Start) - 1
+		for period := 0; period < cfg.Lifespan; period++ {
+			counts[thisIndex]++
+			if period < cfg.Lifespan-1 {
+				thisIndex = int(rows[thisIndex].Rand())
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// Periods returns, for every trial, the number of periods spent in any of the given deciles
+func (r Result) Periods(deciles ...int8) []float64 {
+	periods := make([]float64, r.Trials)
+	for trial := 0; trial < r.Trials; trial++ {
+		for _, d := range deciles {
+			if d < 1 || int(d) > r.Deciles {
+				continue
+			}
+			periods[trial] += float64(r.occupancy[trial*r.Deciles+int(d)-1])
+		}
+	}
+	return periods
+}
+
+// OccupancyOf returns the distribution, across all trials, of the number of periods
+// spent in any of the given deciles
+func (r Result) OccupancyOf(deciles ...int8) countr.Counter {
+	return countr.Count(r.Periods(deciles...))
+}
+
+// Occupancy returns the distribution, across all trials, of the number of periods
+// spent in each decile. The Counter for decile d is at index d-1.
+func (r Result) Occupancy() []countr.Counter {
+	occupancy := make([]countr.Counter, r.Deciles)
+	for i := 0; i < r.Deciles; i++ {
+		occupancy[i] = r.OccupancyOf(int8(i + 1))
+	}
+	return occupancy
+}
+
+// TailProbability is the Monte Carlo p-value of observing at least `observed` periods in
+// the given deciles: the share of trials that did at least as well, counting the observation
+// itself as one more trial so that the p-value is never exactly zero
+func (r Result) TailProbability(observed float64, deciles ...int8) float64 {
+	var atLeast float64
+	for _, p := range r.Periods(deciles...) {
+		if p >= observed {
+			atLeast++
+		}
+	}
+	return (atLeast + 1) / float64(r.Trials+1)
+}
+
+// Significant returns the TailProbability of the observed periods in the given deciles, and
+// whether it falls below the significance level alpha, i.e. whether the observation is too
+// unlikely under the simulated model to be put down to luck
+func (r Result) Significant(observed, alpha float64, deciles ...int8) (pValue float64, significant bool, err error) {
+	if alpha <= 0 || alpha >= 1 {
+		return 0, false, InvalidAlphaError
+	}
+	pValue = r.TailProbability(observed, deciles...)
+	return pValue, pValue < alpha, nil
+}
@@ -0,0 +1,169 @@
+package simulatr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Viking2012/goraynor/src/transitions"
+)
+
+var (
+	// a deterministic cycle: 1 -> 2 -> 3 -> 1
+	cycle transitions.Matrix = transitions.Matrix{
+		{0, 1, 0},
+		{0, 0, 1},
+		{1, 0, 0},
+	}
+	// a coin flip between staying and moving
+	coin transitions.Matrix = transitions.Matrix{
+		{0.5, 0.5},
+		{0.5, 0.5},
+	}
+)
+
+func TestSimulateRejectsBadInput(t *testing.T) {
+	type testCase struct {
+		M    transitions.Matrix
+		Cfg  Config
+		Want error
+	}
+	var testCases []testCase = []testCase{
+		{M: transitions.Matrix{{1, 1}, {0, 1}}, Cfg: Config{Start: 1, Lifespan: 1, Trials: 1}, Want: transitions.NotStochasticError},
+		{M: transitions.Matrix{{1.5, -0.5}, {0, 1}}, Cfg: Config{Start: 1, Lifespan: 1, Trials: 1}, Want: transitions.NegativeProbabilityError},
+		{M: transitions.Matrix{{math.NaN(), 1}, {0, 1}}, Cfg: Config{Start: 1, Lifespan: 1, Trials: 1}, Want: transitions.NegativeProbabilityError},
+		{M: transitions.Matrix{{0, 0, 1}, {0.5, 0.5}}, Cfg: Config{Start: 2, Lifespan: 3, Trials: 1}, Want: transitions.NotSquareError},
+		{M: transitions.Matrix{}, Cfg: Config{Start: 1, Lifespan: 1, Trials: 1}, Want: transitions.InvalidSizeError},
+		{M: coin, Cfg: Config{Start: 0, Lifespan: 1, Trials: 1}, Want: InvalidStartError},
+		{M: coin, Cfg: Config{Start: 3, Lifespan: 1, Trials: 1}, Want: InvalidStartError},
+		{M: coin, Cfg: Config{Start: 1, Lifespan: 0, Trials: 1}, Want: InvalidLifespanError},
+		{M: coin, Cfg: Config{Start: 1, Lifespan: 1, Trials: 0}, Want: InvalidTrialsError},
+	}
+
+	for i, tc := range testCases {
+		_, err := Simulate(tc.M, tc.Cfg)
+		if err != tc.Want {
+			t.Errorf("For test case %d, wanted error %v, but got %v", i, tc.Want, err)
+		}
+	}
+}
+
+func TestDeprecatedValidateMatchesTheMatrix(t *testing.T) {
+	for _, m := range []transitions.Matrix{coin, {{1, 1}, {0, 1}}, {{1.5, -0.5}, {0, 1}}} {
+		if got, want := Validate(m), m.Validate(); got != want {
+			t.Errorf("For %v, wanted %v, but got %v", m, want, got)
+		}
+	}
+}
+
+func TestSimulateStartsInStartingDecile(t *testing.T) {
+	r, err := Simulate(cycle, Config{Start: 2, Lifespan: 1, Trials: 5, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for trial, p := range r.Periods(2) {
+		if p != 1 {
+			t.Errorf("For trial %d, a one period lifespan should be spent entirely in decile 2, but got %3.1f periods", trial, p)
+		}
+	}
+}
+
+func TestSimulateFollowsDeterministicChain(t *testing.T) {
+	r, err := Simulate(cycle, Config{Start: 1, Lifespan: 7, Trials: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 2 3 1 2 3 1
+	want := []float64{3, 2, 2}
+	occupancy := r.Occupancy()
+	for i, c := range occupancy {
+		if c.Len() != 1 {
+			t.Errorf("For decile %d, every trial should have the same occupancy, but got %v", i+1, c)
+			continue
+		}
+		if c[0].Value != want[i] || c[0].Count != 3 {
+			t.Errorf("For decile %d, wanted 3 trials spending %3.1f periods, but got %v", i+1, want[i], c)
+		}
+	}
+}
+
+func TestSimulateOccupancySumsToLifespan(t *testing.T) {
+	r, err := Simulate(coin, Config{Start: 1, Lifespan: 12, Trials: 100, Seed: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for trial, p := range r.Periods(1, 2) {
+		if p != 12 {
+			t.Errorf("For trial %d, periods across all deciles should sum to the lifespan of 12, but got %3.1f", trial, p)
+		}
+	}
+}
+
+func TestSimulateIsReproducibleFromSeed(t *testing.T) {
+	cfg := Config{Start: 1, Lifespan: 20, Trials: 50, Seed: 123456}
+	a, _ := Simulate(coin, cfg)
+	b, _ := Simulate(coin, cfg)
+	cfg.Seed++
+	c, _ := Simulate(coin, cfg)
+
+	pa, pb, pc := a.Periods(1), b.Periods(1), c.Periods(1)
+	var differs bool
+	for i := range pa {
+		if pa[i] != pb[i] {
+			t.Fatalf("For trial %d, the same seed gave %3.1f and %3.1f periods", i, pa[i], pb[i])
+		}
+		if pa[i] != pc[i] {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Error("A different seed should have given different trajectories")
+	}
+}
+
+func TestOccupancyOfCombinesDeciles(t *testing.T) {
+	r, _ := Simulate(cycle, Config{Start: 1, Lifespan: 7, Trials: 2, Seed: 1})
+	got := r.OccupancyOf(2, 3)
+	if got.Len() != 1 || got[0].Value != 4 || got[0].Count != 2 {
+		t.Errorf("OccupancyOf(2, 3) should have been 4 periods in both trials, but got %v", got)
+	}
+}
+
+func TestTailProbability(t *testing.T) {
+	r, _ := Simulate(cycle, Config{Start: 1, Lifespan: 7, Trials: 9, Seed: 1})
+
+	type testCase struct {
+		Observed float64
+		Want     float64
+	}
+	var testCases []testCase = []testCase{
+		{Observed: 0, Want: 1},
+		{Observed: 3, Want: 1},
+		{Observed: 4, Want: 0.1},
+	}
+
+	for _, tc := range testCases {
+		got := r.TailProbability(tc.Observed, 1)
+		if got != tc.Want {
+			t.Errorf("For %3.1f observed periods in decile 1, wanted a p-value of %3.2f, but got %3.2f", tc.Observed, tc.Want, got)
+		}
+	}
+}
+
+func TestSignificant(t *testing.T) {
+	r, _ := Simulate(cycle, Config{Start: 1, Lifespan: 7, Trials: 9, Seed: 1})
+
+	if p, significant, err := r.Significant(4, 0.2, 1); p != 0.1 || !significant || err != nil {
+		t.Errorf("For 4 periods at alpha 0.2, wanted a significant p-value of 0.10, but got %3.2f (%v, %v)", p, significant, err)
+	}
+	if p, significant, err := r.Significant(4, 0.1, 1); p != 0.1 || significant || err != nil {
+		t.Errorf("For 4 periods at alpha 0.1, wanted an insignificant p-value of 0.10, but got %3.2f (%v, %v)", p, significant, err)
+	}
+	for _, alpha := range []float64{0, 1} {
+		if _, _, err := r.Significant(4, alpha, 1); err != InvalidAlphaError {
+			t.Errorf("For alpha %v, wanted %v, but got %v", alpha, InvalidAlphaError, err)
+		}
+	}
+}
@@ -5,15 +5,40 @@ import (
 	"gonum.org/v1/gonum/stat/distuv"
 )
 
+// ProductContainer holds every customer's purchases of a single product, along with the
+// product's decile model once it has been fitted
 type ProductContainer struct {
 	ProductID       string
-	DecileModel     distuv.Categorical
+	Deciles         quantilr.NTiles    // the deciles of every purchase price of the product
+	DecileModel     distuv.Categorical // the probability of a purchase falling in each decile
+	Seed            uint64             // the seed DecileModel samples with
 	CustomerRecords []CustomerContainer
 }
 
+// CustomerContainer holds a customer's purchases of one product, in date order
 type CustomerContainer struct {
 	CustomerID   string
 	PriceRecords []PriceRecord
-	LifeSpan     int16
-	DecileCounts []quantilr.DecilePair
+	LifeSpan     int                   // the number of purchases
+	DecileCounts []quantilr.DecilePair // the number of purchases (as Weight) in each decile found
+}
+
+// CountDeciles sets LifeSpan and DecileCounts from the customer's PriceRecords. Records without
+// a bucket are counted in the LifeSpan only.
+func (c *CustomerContainer) CountDeciles() {
+	c.LifeSpan = len(c.PriceRecords)
+
+	var counts = make(map[int8]float64)
+	for _, pr := range c.PriceRecords {
+		if pr.Bucket > 0 {
+			counts[pr.Bucket]++
+		}
+	}
+
+	c.DecileCounts = make([]quantilr.DecilePair, 0, len(counts))
+	for d := int8(1); d > 0 && len(c.DecileCounts) < len(counts); d++ {
+		if n, ok := counts[d]; ok {
+			c.DecileCounts = append(c.DecileCounts, quantilr.DecilePair{Decile: d, Weight: n})
+		}
+	}
 }
@@ -1,70 +1,341 @@
 package structs
 
 import (
+	"fmt"
+	"math"
+	"sort"
 	"time"
 
+	"github.com/Viking2012/goraynor/src/countr"
 	"github.com/Viking2012/goraynor/src/quantilr"
 )
 
-// OLD VERSION OF A PRICE RECORD!!!
-// A PriceRecord contains all of the information on individual purchases and their prices to identify outliers
-// type PriceRecord struct {
-// 	Uuid               int64
-// 	ProductID          string
-// 	CustomerID         string
-// 	PurchaseDate       time.Time
-// 	DocumentNumber     int64
-// 	DocumentLineNumber int64
-// 	Price              float64
-// }
-
-// TODO(ajo): remove this struct and below methods into somehwere else
-// perhaps replacing "PriceRecord" in the structs folder?
+// A PriceRecord is a single observation of a value for an entity in a period. It serves both
+// pipelines: a purchase is keyed by product, customer and document, and holds the price paid
+// on the purchase date, while a ticker's return is keyed by the ticker (as EntityID) and holds
+// the return for the period ending on the date. Keys which do not apply are left empty.
 type PriceRecord struct {
-	TickerDate    time.Time
-	PriceReturn   float64
-	DecileOfPrice int8
+	Uuid               int64
+	EntityID           string // the entity ranked by itself, such as a ticker, rather than by product and customer
+	ProductID          string
+	CustomerID         string
+	DocumentNumber     int64
+	DocumentLineNumber int64
+
+	Period time.Time // when the value was observed
+	Value  float64   // the price or return
+	Bucket int8      // the n-tile (e.g. decile) Value falls into, or 0 if not yet set
+}
+
+// SetDecile assigns the n-tile (a decile, for quantilr.Deciles) which the value falls into.
+// The boundaries are compiled on every call; use SetBucket to assign many records.
+func (pr *PriceRecord) SetDecile(d *quantilr.NTiles) error {
+	t, err := d.Compile()
+	if err != nil {
+		return err
+	}
+	return pr.SetBucket(t)
 }
 
-func (pr *PriceRecord) SetDecile(d *quantilr.Deciles) error {
-	thisDecile, err := d.LookupValue(pr.PriceReturn)
+// SetBucket assigns the n-tile of the compiled boundaries which the value falls into
+func (pr *PriceRecord) SetBucket(t *quantilr.Table) error {
+	thisDecile, err := t.Lookup(pr.Value)
 	if err != nil {
 		return err
 	}
-	(*pr).DecileOfPrice = thisDecile
+	(*pr).Bucket = thisDecile
 	return nil
 }
 
-// TODO(ajo): remove this struct and below methods into somehwere else
-// perhaps replacing "PriceRecord" in the structs folder?
+// PriceRecords are the records of a single entity, such as one ticker, in date order
 type PriceRecords []PriceRecord
 
 func (a PriceRecords) Len() int               { return len(a) }
 func (a PriceRecords) Swap(i, j int)          { a[i], a[j] = a[j], a[i] }
-func (a PriceRecords) Less(i, j int) bool     { return a[i].TickerDate.Before(a[j].TickerDate) }
+func (a PriceRecords) Less(i, j int) bool     { return a[i].Period.Before(a[j].Period) }
 func (a PriceRecords) Get(i int) *PriceRecord { return &a[i] }
 
-func (prs *PriceRecords) SetDeciles(d *quantilr.Deciles) error {
+// SetDeciles assigns every record its n-tile, compiling the boundaries once
+func (prs *PriceRecords) SetDeciles(d *quantilr.NTiles) (RangeReport, error) {
+	t, err := d.Compile()
+	if err != nil {
+		return RangeReport{}, err
+	}
+	return prs.SetBuckets(t)
+}
+
+// RangeReport counts the records an assignment found outside the range the boundaries were
+// fitted on, whatever the boundaries' RangePolicy did with them: clamped them, gave them a
+// sentinel bucket, left them unassigned or, below the range under quantilr.ErrorOutOfRange,
+// gave them the lowest n-tile
+type RangeReport struct {
+	Records int     // the number of records looked up
+	Below   int     // records below the smallest fitted value, when it is known
+	Above   int     // records above the highest boundary
+	NaN     int     // records whose value is not a number
+	Lowest  float64 // the lowest value below the range
+	Highest float64 // the highest value above the range
+}
+
+func newRangeReport() RangeReport {
+	return RangeReport{Lowest: math.Inf(1), Highest: math.Inf(-1)}
+}
+
+func (r *RangeReport) add(v float64, t *quantilr.Table) {
+	r.Records++
+	switch {
+	case math.IsNaN(v):
+		r.NaN++
+	case v > t.Highest():
+		r.Above++
+		r.Highest = math.Max(r.Highest, v)
+	case v < t.Min():
+		r.Below++
+		r.Lowest = math.Min(r.Lowest, v)
+	}
+}
+
+// OutOfRange returns the number of records outside the fitted range
+func (r RangeReport) OutOfRange() int { return r.Below + r.Above + r.NaN }
+
+func (r RangeReport) String() string {
+	msg := fmt.Sprintf("%d of %d records were out of range:", r.OutOfRange(), r.Records)
+	if r.Below > 0 {
+		msg += fmt.Sprintf(" %d below (lowest %g)", r.Below, r.Lowest)
+	}
+	if r.Above > 0 {
+		msg += fmt.Sprintf(" %d above (highest %g)", r.Above, r.Highest)
+	}
+	if r.NaN > 0 {
+		msg += fmt.Sprintf(" %d not a number", r.NaN)
+	}
+	return msg
+}
+
+// OutOfRangeError is returned when records could not be assigned an n-tile, because their
+// values were above the highest boundary under quantilr.ErrorOutOfRange, or not a number under
+// any policy. Those records are left without a bucket (0); every other record is assigned as usual.
+type OutOfRangeError struct {
+	RangeReport
+	Unassigned int // the number of records left without a bucket
+}
+
+func (e *OutOfRangeError) Error() string {
+	return fmt.Sprintf("%d records could not be assigned an n-tile; %v", e.Unassigned, e.RangeReport)
+}
+
+// Unwrap makes an OutOfRangeError match quantilr.ValueNotFound with errors.Is
+func (e *OutOfRangeError) Unwrap() error { return quantilr.ValueNotFound }
+
+// SetBuckets assigns every record its n-tile of the compiled boundaries, reporting how many were
+// out of range. Records which cannot be assigned are tallied in an *OutOfRangeError rather than
+// stopping at the first.
+func (prs *PriceRecords) SetBuckets(t *quantilr.Table) (RangeReport, error) {
+	report := newRangeReport()
+	unassigned, err := prs.setBuckets(t, &report)
+	if err != nil {
+		return report, err
+	}
+	if unassigned > 0 {
+		return report, &OutOfRangeError{RangeReport: report, Unassigned: unassigned}
+	}
+	return report, nil
+}
+
+// setBuckets assigns every record its n-tile, adding each to the report, and returns the number
+// left without a bucket
+func (prs *PriceRecords) setBuckets(t *quantilr.Table, report *RangeReport) (int, error) {
+	var unassigned int
 	for i := 0; i < len(*prs); i++ {
 		pr := prs.Get(i)
-		err := pr.SetDecile(d)
+		report.add(pr.Value, t)
+		err := pr.SetBucket(t)
+		if err == quantilr.ValueNotFound {
+			pr.Bucket = 0
+			unassigned++
+			continue
+		}
 		if err != nil {
-			return err
+			return unassigned, err
 		}
 	}
-	return nil
+	return unassigned, nil
 }
 
-// TODO(ajo): remove this struct and below methods into somehwere else
-// perhaps replacing "PriceRecord" in the structs folder?
+// AllPerformers holds the records of every entity, keyed by entity
 type AllPerformers map[string]*PriceRecords
 
-func (ap *AllPerformers) SetDeciles(d *quantilr.Deciles) error {
+// SetDeciles assigns every entity's records their n-tile, compiling the boundaries once
+func (ap *AllPerformers) SetDeciles(d *quantilr.NTiles) (RangeReport, error) {
+	t, err := d.Compile()
+	if err != nil {
+		return RangeReport{}, err
+	}
+	return ap.SetBuckets(t)
+}
+
+// SetBuckets assigns every entity's records their n-tile of the compiled boundaries, reporting
+// how many were out of range across every entity. Records which cannot be assigned are tallied
+// in an *OutOfRangeError.
+func (ap *AllPerformers) SetBuckets(t *quantilr.Table) (RangeReport, error) {
+	report := newRangeReport()
+	var unassigned int
 	for _, pr := range *ap {
-		err := pr.SetDeciles(d)
+		if pr == nil {
+			continue
+		}
+		n, err := pr.setBuckets(t, &report)
+		unassigned += n
+		if err != nil {
+			return report, err
+		}
+	}
+	if unassigned > 0 {
+		return report, &OutOfRangeError{RangeReport: report, Unassigned: unassigned}
+	}
+	return report, nil
+}
+
+// NearBoundary returns the index of every record whose value falls within the uncertainty band
+// of an n-tile boundary (see quantilr.Intervals.Near), and so whose bucket is in doubt
+func (prs PriceRecords) NearBoundary(ivs quantilr.Intervals) []int {
+	var near []int
+	for i, pr := range prs {
+		if _, ok := ivs.Near(pr.Value); ok {
+			near = append(near, i)
+		}
+	}
+	return near
+}
+
+// NearBoundary is PriceRecords.NearBoundary for every entity, leaving out entities with none
+func (ap *AllPerformers) NearBoundary(ivs quantilr.Intervals) map[string][]int {
+	flagged := make(map[string][]int)
+	for entity, records := range *ap {
+		if records == nil {
+			continue
+		}
+		if near := records.NearBoundary(ivs); len(near) > 0 {
+			flagged[entity] = near
+		}
+	}
+	return flagged
+}
+
+// PeriodFunc maps the date of a record onto the start of the period it is ranked within
+type PeriodFunc func(t time.Time) time.Time
+
+func byDate(t time.Time) time.Time {
+	return t
+}
+
+func byMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func byQuarter(t time.Time) time.Time {
+	firstMonth := time.Month((int(t.Month())-1)/3*3 + 1)
+	return time.Date(t.Year(), firstMonth, 1, 0, 0, 0, 0, t.Location())
+}
+
+func byYear(t time.Time) time.Time {
+	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+var ByDate PeriodFunc = byDate
+var ByMonth PeriodFunc = byMonth
+var ByQuarter PeriodFunc = byQuarter
+var ByYear PeriodFunc = byYear
+
+// SetDecilesByPeriod ranks every record against its peers in the same period, rather than
+// against one pooled set of deciles. Records are grouped by period(pr.Period), deciles are
+// computed over each group's values, each weighted by weight (nil counts each once), and
+// assigned within the group (see SetWeightedBuckets). The deciles used for each period are
+// returned, keyed by the (UTC) start of the period.
+func (ap *AllPerformers) SetDecilesByPeriod(period PeriodFunc, weight WeightFunc) (map[time.Time]quantilr.Deciles, error) {
+	return ap.SetNTilesByPeriod(period, 10, weight)
+}
+
+// SetNTilesByPeriod is SetDecilesByPeriod for n equally probable buckets (e.g. 5 for quintiles)
+func (ap *AllPerformers) SetNTilesByPeriod(period PeriodFunc, n int, weight WeightFunc) (map[time.Time]quantilr.NTiles, error) {
+	if weight == nil {
+		weight = Unweighted
+	}
+	peers := ap.peersByPeriod(period)
+
+	var periodDeciles = make(map[time.Time]quantilr.NTiles, len(peers))
+	for thisPeriod, records := range peers {
+		values := make([]float64, len(records))
+		weights := make([]float64, len(records))
+		for i, pr := range records {
+			values[i] = pr.Value
+			weights[i] = weight(*pr)
+		}
+
+		counts, err := countr.CountWeighted(values, weights)
+		if err != nil {
+			return nil, err
+		}
+		d, err := quantilr.NewNTiles(counts, n, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := SetWeightedBuckets(records, d, weight); err != nil {
+			return nil, err
+		}
+		periodDeciles[thisPeriod] = d
+	}
+
+	return periodDeciles, nil
+}
+
+// SetBucketsByRank is SetNTilesByPeriod by rank rather than by value boundaries (see
+// quantilr.AssignByRank), so that every period's buckets are equally populated however many
+// values are tied. Each period's RandomTies seed is derived from cfg.Seed and the period.
+func (ap *AllPerformers) SetBucketsByRank(period PeriodFunc, cfg quantilr.RankConfig) error {
+	for thisPeriod, records := range ap.peersByPeriod(period) {
+		values := make([]float64, len(records))
+		for i, pr := range records {
+			values[i] = pr.Value
+		}
+
+		periodCfg := cfg
+		periodCfg.Seed = cfg.Seed ^ uint64(thisPeriod.Unix())
+		buckets, err := quantilr.AssignByRank(values, periodCfg)
 		if err != nil {
 			return err
 		}
+		for i, pr := range records {
+			pr.Bucket = buckets[i]
+		}
 	}
 	return nil
 }
+
+// peersByPeriod groups every record by period(pr.Period), keyed by the (UTC) start of the period.
+// Entities are visited in key order, so each group's records are always in the same order.
+func (ap *AllPerformers) peersByPeriod(period PeriodFunc) map[time.Time][]*PriceRecord {
+	if period == nil {
+		period = ByDate
+	}
+
+	entities := make([]string, 0, len(*ap))
+	for entity := range *ap {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+
+	var peers = make(map[time.Time][]*PriceRecord)
+	for _, entity := range entities {
+		prs := (*ap)[entity]
+		if prs == nil {
+			continue
+		}
+		for i := 0; i < len(*prs); i++ {
+			pr := prs.Get(i)
+			thisPeriod := period(pr.Period).UTC()
+			peers[thisPeriod] = append(peers[thisPeriod], pr)
+		}
+	}
+	return peers
+}
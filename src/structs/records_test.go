@@ -1,8 +1,12 @@
 package structs
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Viking2012/goraynor/src/countr"
 	"github.com/Viking2012/goraynor/src/quantilr"
 )
 
@@ -27,16 +31,16 @@ func TestPriceRecordHasDecileSet(t *testing.T) {
 		Want int8
 	}
 	var testCases []testCase = []testCase{
-		{PR: &PriceRecord{PriceReturn: -1}, Want: 1},
-		{PR: &PriceRecord{PriceReturn: 9}, Want: 2},
-		{PR: &PriceRecord{PriceReturn: 11}, Want: 3},
-		{PR: &PriceRecord{PriceReturn: 21}, Want: 4},
-		{PR: &PriceRecord{PriceReturn: 39}, Want: 5},
-		{PR: &PriceRecord{PriceReturn: 41}, Want: 6},
-		{PR: &PriceRecord{PriceReturn: 59.999}, Want: 7},
-		{PR: &PriceRecord{PriceReturn: 69.999}, Want: 8},
-		{PR: &PriceRecord{PriceReturn: 80}, Want: 9},
-		{PR: &PriceRecord{PriceReturn: 89}, Want: 10},
+		{PR: &PriceRecord{Value: -1}, Want: 1},
+		{PR: &PriceRecord{Value: 9}, Want: 2},
+		{PR: &PriceRecord{Value: 11}, Want: 3},
+		{PR: &PriceRecord{Value: 21}, Want: 4},
+		{PR: &PriceRecord{Value: 39}, Want: 5},
+		{PR: &PriceRecord{Value: 41}, Want: 6},
+		{PR: &PriceRecord{Value: 59.999}, Want: 7},
+		{PR: &PriceRecord{Value: 69.999}, Want: 8},
+		{PR: &PriceRecord{Value: 80}, Want: 9},
+		{PR: &PriceRecord{Value: 89}, Want: 10},
 	}
 
 	for i := 0; i < len(testCases); i++ {
@@ -45,8 +49,229 @@ func TestPriceRecordHasDecileSet(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		if pr.DecileOfPrice != want {
-			t.Errorf("For price record %v, wanted decile %d, but got %d", pr, want, pr.DecileOfPrice)
+		if pr.Bucket != want {
+			t.Errorf("For price record %v, wanted decile %d, but got %d", pr, want, pr.Bucket)
+		}
+	}
+}
+
+func TestSetDecilesTalliesOutOfRangeRecords(t *testing.T) {
+	records := PriceRecords{{Value: 5}, {Value: 95}, {Value: 120}, {Value: 45, Bucket: 3}}
+	ap := AllPerformers{"a": &records, "b": &PriceRecords{{Value: 99}}}
+
+	report, err := ap.SetDeciles(&testDeciles)
+	var outOfRange *OutOfRangeError
+	if !errors.As(err, &outOfRange) {
+		t.Fatalf("For records above the deciles, wanted an OutOfRangeError, but got %v", err)
+	}
+	if !errors.Is(err, quantilr.ValueNotFound) {
+		t.Errorf("For the OutOfRangeError, wanted it to match %v", quantilr.ValueNotFound)
+	}
+	if outOfRange.Records != 5 || outOfRange.Above != 3 || outOfRange.Highest != 120 || outOfRange.Unassigned != 3 {
+		t.Errorf("For the tally, wanted 3 of 5 above with highest 120 and unassigned, but got %+v", *outOfRange)
+	}
+	if report != outOfRange.RangeReport {
+		t.Errorf("For the report, wanted the error's %+v, but got %+v", outOfRange.RangeReport, report)
+	}
+
+	wantBuckets := []int8{2, 0, 0, 6}
+	for i, want := range wantBuckets {
+		if records[i].Bucket != want {
+			t.Errorf("For record %d, wanted bucket %d, but got %d", i, want, records[i].Bucket)
+		}
+	}
+}
+
+func TestSetDecilesFollowsRangePolicy(t *testing.T) {
+	type testCase struct {
+		Policy quantilr.RangePolicy
+		Want   int8
+	}
+	var testCases []testCase = []testCase{
+		{Policy: quantilr.ClampToRange, Want: 10},
+		{Policy: quantilr.SentinelOutOfRange, Want: quantilr.AboveRange},
+	}
+
+	for _, tc := range testCases {
+		d := testDeciles
+		d.OutOfRange = tc.Policy
+		records := PriceRecords{{Value: 5}, {Value: 95}}
+		report, err := records.SetDeciles(&d)
+		if err != nil {
+			t.Errorf("For %v, wanted no error, but got %v", tc.Policy, err)
+		}
+		if records[0].Bucket != 2 || records[1].Bucket != tc.Want {
+			t.Errorf("For %v, wanted buckets 2 and %d, but got %d and %d", tc.Policy, tc.Want, records[0].Bucket, records[1].Bucket)
+		}
+		if report.Records != 2 || report.Above != 1 || report.OutOfRange() != 1 || report.Highest != 95 {
+			t.Errorf("For %v, wanted 1 of 2 records reported above the range, but got %+v", tc.Policy, report)
+		}
+	}
+}
+
+func TestSetDecilesReportsValuesBelowTheFittedRange(t *testing.T) {
+	d, err := quantilr.NewDeciles(countr.Count([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		Policy quantilr.RangePolicy
+		Want   int8
+	}
+	var testCases []testCase = []testCase{
+		{Policy: quantilr.ErrorOutOfRange, Want: 1},
+		{Policy: quantilr.ClampToRange, Want: 1},
+		{Policy: quantilr.SentinelOutOfRange, Want: quantilr.BelowRange},
+	}
+
+	for _, tc := range testCases {
+		d.OutOfRange = tc.Policy
+		records := PriceRecords{{Value: 0.5}, {Value: 5}}
+		report, err := records.SetDeciles(&d)
+		if err != nil {
+			t.Errorf("For %v, wanted no error, but got %v", tc.Policy, err)
+		}
+		if records[0].Bucket != tc.Want {
+			t.Errorf("For %v, wanted bucket %d, but got %d", tc.Policy, tc.Want, records[0].Bucket)
+		}
+		if report.Below != 1 || report.Lowest != 0.5 || report.OutOfRange() != 1 {
+			t.Errorf("For %v, wanted 1 record reported below the range at 0.5, but got %+v", tc.Policy, report)
+		}
+	}
+}
+
+func TestNearBoundary(t *testing.T) {
+	ivs := quantilr.Intervals{
+		{Decile: 1, Probability: 0.5, Estimate: 10, Lower: 8, Upper: 12},
+		{Decile: 2, Probability: 1, Estimate: 20, Lower: 18, Upper: 20},
+	}
+	ap := AllPerformers{
+		"a": &PriceRecords{{Value: 5}, {Value: 9}, {Value: 12}},
+		"b": &PriceRecords{{Value: 19}},
+	}
+
+	got := ap.NearBoundary(ivs)
+	if len(got) != 1 || len(got["a"]) != 2 || got["a"][0] != 1 || got["a"][1] != 2 {
+		t.Errorf("For records near the middle boundary, wanted a at 1 and 2 only, but got %v", got)
+	}
+}
+
+func TestPeriodFuncs(t *testing.T) {
+	date := time.Date(2021, time.August, 19, 13, 30, 0, 0, time.UTC)
+
+	type testCase struct {
+		Period PeriodFunc
+		Want   time.Time
+	}
+	var testCases []testCase = []testCase{
+		{Period: ByDate, Want: date},
+		{Period: ByMonth, Want: time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)},
+		{Period: ByQuarter, Want: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{Period: ByYear, Want: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for i, tc := range testCases {
+		got := tc.Period(date)
+		if !got.Equal(tc.Want) {
+			t.Errorf("For period function %d, wanted %s, but got %s", i, tc.Want.Format(time.RFC3339), got.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestSetDecilesByPeriodRanksWithinPeriod(t *testing.T) {
+	july := time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC)
+	august := time.Date(2021, time.August, 31, 0, 0, 0, 0, time.UTC)
+
+	// in July, ticker i returns i; in August (a bull market) ticker i returns 1000 - i,
+	// so pooled deciles would put everyone into the top deciles for August
+	ap := make(AllPerformers)
+	for i := 1; i <= 10; i++ {
+		ap[fmt.Sprintf("T%02d", i)] = &PriceRecords{
+			{Period: july, Value: float64(i)},
+			{Period: august, Value: 1000 - float64(i)},
+		}
+	}
+
+	periodDeciles, err := ap.SetDecilesByPeriod(ByMonth, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periodDeciles) != 2 {
+		t.Errorf("SetDecilesByPeriod should have returned deciles for 2 periods, but got %d", len(periodDeciles))
+	}
+
+	for i := 1; i <= 10; i++ {
+		records := *ap[fmt.Sprintf("T%02d", i)]
+		if records[0].Bucket != int8(i) {
+			t.Errorf("For ticker %d in July, wanted decile %d, but got %d", i, i, records[0].Bucket)
+		}
+		if records[1].Bucket != int8(11-i) {
+			t.Errorf("For ticker %d in August, wanted decile %d, but got %d", i, 11-i, records[1].Bucket)
+		}
+	}
+}
+
+func TestSetNTilesByPeriodQuintiles(t *testing.T) {
+	july := time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC)
+
+	ap := make(AllPerformers)
+	for i := 1; i <= 10; i++ {
+		ap[fmt.Sprintf("T%02d", i)] = &PriceRecords{{Period: july, Value: float64(i)}}
+	}
+
+	periodTiles, err := ap.SetNTilesByPeriod(ByMonth, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range periodTiles {
+		if q.N() != 5 {
+			t.Errorf("Quintiles should have N of 5, but got %d", q.N())
+		}
+	}
+
+	for i := 1; i <= 10; i++ {
+		want := int8((i + 1) / 2)
+		got := (*ap[fmt.Sprintf("T%02d", i)])[0].Bucket
+		if got != want {
+			t.Errorf("For ticker %d, wanted quintile %d, but got %d", i, want, got)
+		}
+	}
+}
+
+func TestSetBucketsByRankSplitsTies(t *testing.T) {
+	july := time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC)
+
+	// every ticker returns the same in July, so value boundaries would give a single decile
+	ap := make(AllPerformers)
+	for i := 1; i <= 20; i++ {
+		ap[fmt.Sprintf("T%02d", i)] = &PriceRecords{{Period: july, Value: 0.01}}
+	}
+
+	cfg := quantilr.RankConfig{N: 10, Ties: quantilr.RandomTies, Seed: 123456}
+	if err := ap.SetBucketsByRank(ByMonth, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[int8]int)
+	first := make(map[string]int8)
+	for ticker, records := range ap {
+		counts[(*records)[0].Bucket]++
+		first[ticker] = (*records)[0].Bucket
+	}
+	for d := int8(1); d <= 10; d++ {
+		if counts[d] != 2 {
+			t.Errorf("Decile %d should hold 2 of the 20 tickers, but holds %d", d, counts[d])
+		}
+	}
+
+	// a second run must not depend on map order
+	if err := ap.SetBucketsByRank(ByMonth, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for ticker, records := range ap {
+		if (*records)[0].Bucket != first[ticker] {
+			t.Errorf("For ticker %s, the same seed should give the same decile, but got %d then %d", ticker, first[ticker], (*records)[0].Bucket)
 		}
 	}
 }
@@ -0,0 +1,71 @@
+package structs
+
+import (
+	"github.com/Viking2012/goraynor/src/countr"
+	"github.com/Viking2012/goraynor/src/quantilr"
+)
+
+// WeightFunc gives the weight a record carries when n-tile boundaries are fitted, e.g. its
+// market cap, revenue or quantity
+type WeightFunc func(pr PriceRecord) float64
+
+func unweighted(pr PriceRecord) float64 { return 1 }
+func byValue(pr PriceRecord) float64    { return pr.Value }
+
+var (
+	// Unweighted counts every record once
+	Unweighted WeightFunc = unweighted
+	// ByValue weights every record by its own value, e.g. revenue when Value is a line total
+	ByValue WeightFunc = byValue
+)
+
+// WeightsByUuid weights records by their Uuid, for weights kept outside of the records.
+// Records missing from weights carry no weight.
+func WeightsByUuid(weights map[int64]float64) WeightFunc {
+	return func(pr PriceRecord) float64 { return weights[pr.Uuid] }
+}
+
+// Count counts the records' values, each contributing its weight. A nil weight is Unweighted.
+func (prs PriceRecords) Count(weight WeightFunc) (countr.Counter, error) {
+	if weight == nil {
+		weight = Unweighted
+	}
+
+	values := make([]float64, len(prs))
+	weights := make([]float64, len(prs))
+	for i, pr := range prs {
+		values[i] = pr.Value
+		weights[i] = weight(pr)
+	}
+	return countr.CountWeighted(values, weights)
+}
+
+// SetWeightedBuckets assigns each record its n-tile of boundaries fitted on the records counted
+// with weight. Records which weigh nothing were left out of the boundaries, so they may fall
+// outside of them: they are clamped to the lowest or highest n-tile. A nil weight is Unweighted.
+func SetWeightedBuckets(records []*PriceRecord, d quantilr.NTiles, weight WeightFunc) error {
+	if weight == nil {
+		weight = Unweighted
+	}
+	t, err := d.Compile()
+	if err != nil {
+		return err
+	}
+	clamp := d
+	clamp.OutOfRange = quantilr.ClampToRange
+	clamped, err := clamp.Compile()
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range records {
+		table := t
+		if weight(*pr) == 0 {
+			table = clamped
+		}
+		if err := pr.SetBucket(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package structs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/countr"
+)
+
+func TestPriceRecordsCount(t *testing.T) {
+	records := PriceRecords{{Uuid: 1, Value: 10}, {Uuid: 2, Value: 20}, {Uuid: 3, Value: 10}}
+
+	type testCase struct {
+		Name   string
+		Weight WeightFunc
+		Want   countr.Counter
+	}
+	var testCases []testCase = []testCase{
+		{Name: "nil", Weight: nil, Want: countr.Counter{{Value: 10, Count: 2}, {Value: 20, Count: 1}}},
+		{Name: "Unweighted", Weight: Unweighted, Want: countr.Counter{{Value: 10, Count: 2}, {Value: 20, Count: 1}}},
+		{Name: "ByValue", Weight: ByValue, Want: countr.Counter{{Value: 10, Count: 20}, {Value: 20, Count: 20}}},
+		{Name: "WeightsByUuid", Weight: WeightsByUuid(map[int64]float64{1: 2.5, 3: 0.5}), Want: countr.Counter{{Value: 10, Count: 3}}},
+	}
+
+	for _, tc := range testCases {
+		got, err := records.Count(tc.Weight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.Want) {
+			t.Errorf("For %s, wanted %v, but got %v", tc.Name, tc.Want, got)
+			continue
+		}
+		for i := range tc.Want {
+			if got[i] != tc.Want[i] {
+				t.Errorf("For %s, wanted %v, but got %v", tc.Name, tc.Want, got)
+			}
+		}
+	}
+}
+
+func TestPriceRecordsCountRejectsNegativeWeights(t *testing.T) {
+	records := PriceRecords{{Value: -0.05}, {Value: 0.1}}
+	if _, err := records.Count(ByValue); err != countr.InvalidWeightError {
+		t.Errorf("For a negative return weighted by value, wanted %v, but got %v", countr.InvalidWeightError, err)
+	}
+}
+
+func TestSetDecilesByPeriodWeighted(t *testing.T) {
+	july := time.Date(2021, time.July, 30, 0, 0, 0, 0, time.UTC)
+
+	// ticker 10 is worth as much as the other nine together; ticker 11 has no market cap
+	ap := make(AllPerformers)
+	caps := make(map[int64]float64)
+	for i := 1; i <= 11; i++ {
+		ap[fmt.Sprintf("T%02d", i)] = &PriceRecords{{Uuid: int64(i), Period: july, Value: float64(i)}}
+		caps[int64(i)] = 1
+	}
+	caps[10], caps[11] = 9, 0
+
+	if _, err := ap.SetDecilesByPeriod(ByMonth, WeightsByUuid(caps)); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int8{"T01": 1, "T09": 5, "T10": 10, "T11": 10}
+	for ticker, decile := range want {
+		if got := (*ap[ticker])[0].Bucket; got != decile {
+			t.Errorf("For %s weighted by market cap, wanted decile %d, but got %d", ticker, decile, got)
+		}
+	}
+}
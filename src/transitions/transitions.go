@@ -0,0 +1,307 @@
+package transitions
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+var (
+	InvalidSizeError   error = errors.New("a transition matrix must have at least one decile")
+	SizeMismatchError  error = errors.New("the transition matrices provided are not the same size")
+	DecileOutOfRange   error = errors.New("the decile provided falls outside of the transition matrix")
+	EmptyRowError      error = errors.New("encountered a decile with no observed transitions out of it")
+	NotStochasticError error = errors.New("each row of the transition matrix must sum to 1 (see Matrix.Normalize)")
+	NotConvergedError  error = errors.New("the stationary distribution did not converge")
+	NotSquareError     error = errors.New("every row of the transition matrix must have one column per decile")
+
+	NegativeProbabilityError error = errors.New("every entry of the transition matrix must be a probability, not negative or NaN")
+)
+
+// StochasticTolerance is how far a row of a transition matrix may sum away from 1 (floating
+// point noise from Normalize) before it is rejected
+const StochasticTolerance = 1e-9
+
+// EmptyRowPolicy decides what Normalize does with a decile that was never left
+// (a row of all zeros), which is common for per-entity matrices built from short histories
+type EmptyRowPolicy int
+
+const (
+	// LeaveEmpty keeps the row as all zeros, so the normalized matrix is only sub-stochastic
+	LeaveEmpty EmptyRowPolicy = iota
+	// UniformRow treats every decile as an equally likely next step
+	UniformRow
+	// StayInPlace makes the decile absorbing: once entered, it is never left
+	StayInPlace
+	// ErrorOnEmpty refuses to normalize and returns EmptyRowError
+	ErrorOnEmpty
+)
+
+func decileToIndex(d int8) int {
+	return int(d) - 1
+}
+
+func indexToDecile(i int) int8 {
+	return int8(i + 1)
+}
+
+// Matrix is a square decile-to-decile transition matrix. Rows are the decile an entity
+// was in, columns the decile it moved to in the following period. Deciles are 1-indexed
+// (as they are on PriceRecord.Bucket), the underlying slices are 0-indexed.
+// A freshly built Matrix holds counts; Normalize turns it into row probabilities.
+type Matrix [][]float64
+
+// NewMatrix returns an empty (all zero) n×n transition matrix
+func NewMatrix(n int) (Matrix, error) {
+	if n < 1 {
+		return nil, InvalidSizeError
+	}
+
+	m := make(Matrix, n)
+	for i := 0; i < n; i++ {
+		m[i] = make([]float64, n)
+	}
+	return m, nil
+}
+
+// Size returns the number of deciles (rows and columns) in the matrix
+func (m Matrix) Size() int { return len(m) }
+
+func (m Matrix) inRange(d int8) bool {
+	return d >= 1 && int(d) <= m.Size()
+}
+
+// Add counts a single move from one decile to the next
+func (m Matrix) Add(from, to int8) error {
+	if !m.inRange(from) || !m.inRange(to) {
+		return DecileOutOfRange
+	}
+	m[decileToIndex(from)][decileToIndex(to)]++
+	return nil
+}
+
+// Get returns the value (count or probability) of moving between the two deciles
+func (m Matrix) Get(from, to int8) float64 {
+	if !m.inRange(from) || !m.inRange(to) {
+		return 0
+	}
+	return m[decileToIndex(from)][decileToIndex(to)]
+}
+
+// AddRecords counts every transition between consecutive periods of a single entity.
+// The records are walked in chronological order (a sorted copy is used if they are not
+// already sorted). Records without an assigned decile (zero, or the -1 returned for values
+// outside of the decile range) break the sequence and are not counted.
+func (m Matrix) AddRecords(records structs.PriceRecords) error {
+	if !sort.IsSorted(records) {
+		sorted := make(structs.PriceRecords, len(records))
+		copy(sorted, records)
+		sort.Stable(sorted)
+		records = sorted
+	}
+
+	for i := 1; i < len(records); i++ {
+		from, to := records[i-1].Bucket, records[i].Bucket
+		if from <= 0 || to <= 0 {
+			continue
+		}
+		if err := m.Add(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge adds the counts of another matrix of the same size into this one
+func (m Matrix) Merge(other Matrix) error {
+	if m.Size() != other.Size() {
+		return SizeMismatchError
+	}
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] += other[i][j]
+		}
+	}
+	return nil
+}
+
+// RowSums returns the total of each row, i.e. the number of transitions out of each decile
+func (m Matrix) RowSums() []float64 {
+	sums := make([]float64, m.Size())
+	for i := range m {
+		for _, v := range m[i] {
+			sums[i] += v
+		}
+	}
+	return sums
+}
+
+// Normalize returns a new row-stochastic matrix, where each row holds the probability
+// of moving from that decile to each of the others. The counts are left untouched.
+func (m Matrix) Normalize(policy EmptyRowPolicy) (Matrix, error) {
+	n := m.Size()
+	normalized, err := NewMatrix(n)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := m.RowSums()
+	for i := 0; i < n; i++ {
+		if sums[i] == 0 {
+			switch policy {
+			case UniformRow:
+				for j := 0; j < n; j++ {
+					normalized[i][j] = 1 / float64(n)
+				}
+			case StayInPlace:
+				normalized[i][i] = 1
+			case ErrorOnEmpty:
+				return nil, EmptyRowError
+			}
+			continue
+		}
+
+		for j := 0; j < n; j++ {
+			normalized[i][j] = m[i][j] / sums[i]
+		}
+	}
+
+	return normalized, nil
+}
+
+// EmptyRows returns the deciles which were never transitioned out of
+func (m Matrix) EmptyRows() []int8 {
+	var empty []int8
+	for i, s := range m.RowSums() {
+		if s == 0 {
+			empty = append(empty, indexToDecile(i))
+		}
+	}
+	return empty
+}
+
+// Validate checks that the matrix is square and row-stochastic, as Normalize returns: every
+// entry is a probability and every row sums to 1, so that it can be sampled or iterated
+func (m Matrix) Validate() error {
+	if m.Size() == 0 {
+		return InvalidSizeError
+	}
+	for _, row := range m {
+		if len(row) != m.Size() {
+			return NotSquareError
+		}
+	}
+	for _, row := range m {
+		for _, p := range row {
+			if p < 0 || math.IsNaN(p) {
+				return NegativeProbabilityError
+			}
+		}
+	}
+	for _, s := range m.RowSums() {
+		if math.Abs(s-1) > StochasticTolerance {
+			return NotStochasticError
+		}
+	}
+	return nil
+}
+
+// stationaryTolerance and stationaryIterations bound the power iteration in Stationary
+const (
+	stationaryTolerance  = 1e-12
+	stationaryIterations = 100000
+)
+
+// Stationary returns the long-run share of periods spent in each decile, for a row-stochastic
+// matrix such as Normalize returns. It is found by power iteration from a uniform start, which
+// also picks the distribution returned when the chain has more than one.
+func (m Matrix) Stationary() ([]float64, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	n := m.Size()
+
+	pi := make([]float64, n)
+	for i := range pi {
+		pi[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+
+	for iter := 0; iter < stationaryIterations; iter++ {
+		// step the lazy chain (I+P)/2, which has the same stationary distribution as P but
+		// converges even when P is periodic
+		for j := range next {
+			next[j] = pi[j] / 2
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				next[j] += pi[i] * m[i][j] / 2
+			}
+		}
+
+		var change float64
+		for j := range next {
+			change += math.Abs(next[j] - pi[j])
+		}
+		pi, next = next, pi
+		if change < stationaryTolerance {
+			return pi, nil
+		}
+	}
+	return nil, NotConvergedError
+}
+
+// EntityMatrices maps each entity, e.g. a ticker, to its own transition matrix
+type EntityMatrices map[string]Matrix
+
+// PerEntity builds a separate n×n count matrix for every entity.
+// Deciles must already be assigned, e.g. with AllPerformers.SetDeciles.
+func PerEntity(ap *structs.AllPerformers, n int) (EntityMatrices, error) {
+	em := make(EntityMatrices, len(*ap))
+	for entity, records := range *ap {
+		m, err := NewMatrix(n)
+		if err != nil {
+			return nil, err
+		}
+		if records != nil {
+			if err := m.AddRecords(*records); err != nil {
+				return nil, err
+			}
+		}
+		em[entity] = m
+	}
+	return em, nil
+}
+
+// Pooled builds a single n×n count matrix from the transitions of every entity
+func Pooled(ap *structs.AllPerformers, n int) (Matrix, error) {
+	m, err := NewMatrix(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, records := range *ap {
+		if records == nil {
+			continue
+		}
+		if err := m.AddRecords(*records); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Pooled sums the per-entity matrices into a single count matrix of size n
+func (em EntityMatrices) Pooled(n int) (Matrix, error) {
+	m, err := NewMatrix(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, entityMatrix := range em {
+		if err := m.Merge(entityMatrix); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,261 @@
+package transitions
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Viking2012/goraynor/src/structs"
+)
+
+func month(m int) time.Time {
+	return time.Date(2021, time.Month(m), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// sequence builds monthly records with the given deciles, in the order given
+func sequence(deciles ...int8) *structs.PriceRecords {
+	records := make(structs.PriceRecords, len(deciles))
+	for i, d := range deciles {
+		records[i] = structs.PriceRecord{Period: month(i + 1), Bucket: d}
+	}
+	return &records
+}
+
+func TestNewMatrixRejectsEmptySize(t *testing.T) {
+	_, err := NewMatrix(0)
+	if err != InvalidSizeError {
+		t.Errorf("NewMatrix(0) should have returned an InvalidSizeError, but got %v", err)
+	}
+}
+
+func TestAddRecordsCountsConsecutivePeriods(t *testing.T) {
+	m, _ := NewMatrix(3)
+	err := m.AddRecords(*sequence(1, 2, 2, 3, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Matrix{
+		{0, 1, 0},
+		{0, 1, 1},
+		{1, 0, 0},
+	}
+	for i := range want {
+		for j := range want[i] {
+			if m[i][j] != want[i][j] {
+				t.Errorf("For transition %d -> %d, wanted %3.1f but got %3.1f", i+1, j+1, want[i][j], m[i][j])
+			}
+		}
+	}
+}
+
+func TestAddRecordsSortsChronologically(t *testing.T) {
+	records := structs.PriceRecords{
+		{Period: month(3), Bucket: 3},
+		{Period: month(1), Bucket: 1},
+		{Period: month(2), Bucket: 2},
+	}
+	m, _ := NewMatrix(3)
+	if err := m.AddRecords(records); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Get(1, 2) != 1 || m.Get(2, 3) != 1 || m.Get(3, 1) != 0 {
+		t.Errorf("AddRecords should have counted 1 -> 2 -> 3 in date order, but got %v", m)
+	}
+	if records[0].Bucket != 3 {
+		t.Error("AddRecords should not have reordered the records it was given")
+	}
+}
+
+func TestAddRecordsSkipsUnassignedDeciles(t *testing.T) {
+	m, _ := NewMatrix(3)
+	if err := m.AddRecords(*sequence(1, 0, 2, -1, 3, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	var total float64
+	for _, s := range m.RowSums() {
+		total += s
+	}
+	if total != 1 || m.Get(3, 3) != 1 {
+		t.Errorf("AddRecords should only have counted the 3 -> 3 transition, but got %v", m)
+	}
+}
+
+func TestAddRecordsErrorsOnDecileOutsideMatrix(t *testing.T) {
+	m, _ := NewMatrix(3)
+	err := m.AddRecords(*sequence(1, 4))
+	if err != DecileOutOfRange {
+		t.Errorf("AddRecords should have returned DecileOutOfRange, but got %v", err)
+	}
+}
+
+func TestNormalizeRowsSumToOne(t *testing.T) {
+	m := Matrix{
+		{1, 3, 0},
+		{2, 2, 4},
+		{0, 0, 5},
+	}
+	p, err := m.Normalize(ErrorOnEmpty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, s := range p.RowSums() {
+		if s != 1 {
+			t.Errorf("Row %d of the normalized matrix should sum to 1, but sums to %f", i+1, s)
+		}
+	}
+	if p.Get(1, 2) != 0.75 {
+		t.Errorf("Probability of 1 -> 2 should have been 0.75, but got %f", p.Get(1, 2))
+	}
+	if m.Get(1, 2) != 3 {
+		t.Error("Normalize should not have modified the counts it was called on")
+	}
+}
+
+func TestNormalizeEmptyRowPolicies(t *testing.T) {
+	m := Matrix{
+		{1, 1, 0},
+		{0, 0, 0},
+		{0, 0, 1},
+	}
+
+	type testCase struct {
+		Policy EmptyRowPolicy
+		Want   []float64
+	}
+	var testCases []testCase = []testCase{
+		{Policy: LeaveEmpty, Want: []float64{0, 0, 0}},
+		{Policy: UniformRow, Want: []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+		{Policy: StayInPlace, Want: []float64{0, 1, 0}},
+	}
+
+	for _, tc := range testCases {
+		p, err := m.Normalize(tc.Policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, w := range tc.Want {
+			if p[1][j] != w {
+				t.Errorf("For policy %d, wanted %f for 2 -> %d, but got %f", tc.Policy, w, j+1, p[1][j])
+			}
+		}
+	}
+
+	if _, err := m.Normalize(ErrorOnEmpty); err != EmptyRowError {
+		t.Errorf("Normalize with ErrorOnEmpty should have returned EmptyRowError, but got %v", err)
+	}
+}
+
+func TestEmptyRows(t *testing.T) {
+	m := Matrix{
+		{1, 0, 0},
+		{0, 0, 0},
+		{0, 0, 0},
+	}
+	got := m.EmptyRows()
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("EmptyRows should have returned [2 3], but got %v", got)
+	}
+}
+
+func TestPooledEqualsSumOfPerEntity(t *testing.T) {
+	ap := structs.AllPerformers{
+		"AAA": sequence(1, 2, 3),
+		"BBB": sequence(3, 2, 1),
+		"CCC": nil,
+	}
+
+	per, err := PerEntity(&ap, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(per) != 3 {
+		t.Errorf("PerEntity should have returned a matrix for each of the 3 entities, but got %d", len(per))
+	}
+	if per["AAA"].Get(1, 2) != 1 || per["AAA"].Get(3, 2) != 0 {
+		t.Errorf("Matrix for AAA should only count its own transitions, but got %v", per["AAA"])
+	}
+
+	pooled, err := Pooled(&ap, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summed, err := per.Pooled(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range pooled {
+		for j := range pooled[i] {
+			if pooled[i][j] != summed[i][j] {
+				t.Errorf("For transition %d -> %d, pooled matrix has %3.1f but summed matrix has %3.1f", i+1, j+1, pooled[i][j], summed[i][j])
+			}
+		}
+	}
+}
+
+func TestMergeErrorsOnSizeMismatch(t *testing.T) {
+	a, _ := NewMatrix(2)
+	b, _ := NewMatrix(3)
+	if err := a.Merge(b); err != SizeMismatchError {
+		t.Errorf("Merge should have returned SizeMismatchError, but got %v", err)
+	}
+}
+
+func TestStationary(t *testing.T) {
+	type testCase struct {
+		P    Matrix
+		Want []float64
+	}
+	var testCases []testCase = []testCase{
+		{P: Matrix{{0.9, 0.1}, {0.5, 0.5}}, Want: []float64{5.0 / 6, 1.0 / 6}},
+		// periodic: alternates between the two deciles forever
+		{P: Matrix{{0, 1}, {1, 0}}, Want: []float64{0.5, 0.5}},
+		{P: Matrix{{0.5, 0.5, 0}, {0.25, 0.5, 0.25}, {0, 0.5, 0.5}}, Want: []float64{0.25, 0.5, 0.25}},
+	}
+
+	for i, tc := range testCases {
+		got, err := tc.P.Stationary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range tc.Want {
+			if math.Abs(got[j]-tc.Want[j]) > 1e-9 {
+				t.Errorf("For matrix %d, wanted %v, but got %v", i, tc.Want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestStationaryRejectsCounts(t *testing.T) {
+	if _, err := (Matrix{{1, 3}, {0, 0}}).Stationary(); err != NotStochasticError {
+		t.Errorf("Stationary should have returned NotStochasticError for unnormalized counts, but got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type testCase struct {
+		M    Matrix
+		Want error
+	}
+	var testCases []testCase = []testCase{
+		{M: Matrix{{0.5, 0.5}, {0, 1}}, Want: nil},
+		{M: Matrix{{0.5, 0.5 + 1e-12}, {0, 1}}, Want: nil},
+		{M: Matrix{}, Want: InvalidSizeError},
+		{M: Matrix{{1, 1}, {0, 1}}, Want: NotStochasticError},
+		{M: Matrix{{1.5, -0.5}, {0, 1}}, Want: NegativeProbabilityError},
+		{M: Matrix{{math.NaN(), 1}, {0, 1}}, Want: NegativeProbabilityError},
+		{M: Matrix{{0, 0, 1}, {0.5, 0.5}}, Want: NotSquareError},
+		{M: Matrix{{0.5, 0.5}, {0, 1}, {0, 1}}, Want: NotSquareError},
+	}
+
+	for i, tc := range testCases {
+		if err := tc.M.Validate(); err != tc.Want {
+			t.Errorf("For matrix %d, wanted %v, but got %v", i, tc.Want, err)
+		}
+	}
+}